@@ -0,0 +1,61 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// Wednesday.
+	from := time.Date(2022, 6, 15, 10, 7, 30, 0, time.UTC)
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2022, 6, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2022, 6, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * *", time.Date(2022, 6, 15, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2022, 6, 16, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 8 * * 0", time.Date(2022, 6, 19, 8, 0, 0, 0, time.UTC)},
+		{"0 8 * * 7", time.Date(2022, 6, 19, 8, 0, 0, 0, time.UTC)},
+		{"0 8 20 * 5", time.Date(2022, 6, 17, 8, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+		{"@every 90s", from.Add(90 * time.Second)},
+	}
+	for _, c := range cases {
+		s, err := parseSchedule(c.spec)
+		if err != nil {
+			t.Errorf("parseSchedule(%q): %v", c.spec, err)
+			continue
+		}
+		if got := s.next(from); !got.Equal(c.want) {
+			t.Errorf("next of %q = %v, want %v", c.spec, got, c.want)
+		}
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every 1ms"} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("parseSchedule(%q) expected error", spec)
+		}
+	}
+}
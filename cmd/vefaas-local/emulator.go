@@ -0,0 +1,370 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+const eventsPathPrefix = "/_vefaas-local/events/"
+
+type config struct {
+	listen    string
+	port      int
+	timeout   time.Duration
+	name      string
+	command   []string
+	events    keyValueFlags
+	schedules keyValueFlags
+
+	accessKeyId     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+type emulator struct {
+	cfg    config
+	target *url.URL
+	client *http.Client
+	proxy  *httputil.ReverseProxy
+
+	// internalToken authenticates the internal requests to the function.
+	internalToken string
+}
+
+// run starts the function and serves local traffic until either the function
+// exits or vefaas-local is interrupted, it returns the exit code.
+func run(cfg config) int {
+	schedules := make(map[string]schedule, len(cfg.schedules))
+	for name, spec := range cfg.schedules {
+		if _, ok := cfg.events[name]; !ok {
+			log.Printf("Schedule of unknown event %q, register it with -event.", name)
+			return 2
+		}
+		s, err := parseSchedule(spec)
+		if err != nil {
+			log.Printf("%v.", err)
+			return 2
+		}
+		schedules[name] = s
+	}
+	// Load events eagerly to report broken files before starting the function.
+	for name := range cfg.events {
+		if _, err := loadEvent(cfg.events[name]); err != nil {
+			log.Printf("Failed to load event %q, %v.", name, err)
+			return 2
+		}
+	}
+
+	target, _ := url.Parse(fmt.Sprintf("http://127.0.0.1:%d", cfg.port))
+	e := &emulator{
+		cfg:           cfg,
+		target:        target,
+		client:        &http.Client{Timeout: cfg.timeout},
+		internalToken: newId(),
+	}
+	e.proxy = &httputil.ReverseProxy{Director: e.direct}
+
+	cmd := exec.Command(cfg.command[0], cfg.command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"_FAAS_RUNTIME_PORT="+strconv.Itoa(cfg.port),
+		"_FAAS_FUNC_TIMEOUT="+strconv.Itoa(int(cfg.timeout/time.Second)),
+		"_FAAS_FUNC_NAME="+cfg.name,
+		"VEFAAS_INTERNAL_TOKEN="+e.internalToken,
+	)
+	if err := cmd.Start(); err != nil {
+		log.Printf("Failed to start function, %v.", err)
+		return 1
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	if err := e.initialize(exited); err != nil {
+		log.Printf("Failed to initialize function, %v.", err)
+		return terminate(cmd, exited, cfg.timeout)
+	}
+
+	server := &http.Server{Addr: cfg.listen, Handler: e}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			log.Printf("Local server exited unexpectedly, %v.", err)
+			stop <- syscall.SIGTERM
+		}
+	}()
+	log.Printf("Serving function at http://%s, fire events with POST %s<name>.", cfg.listen, eventsPathPrefix)
+
+	scheduleCtx, cancelSchedules := context.WithCancel(context.Background())
+	defer cancelSchedules()
+	for name, s := range schedules {
+		go e.runSchedule(scheduleCtx, name, s)
+	}
+
+	select {
+	case err := <-exited:
+		log.Printf("Function exited, %v.", err)
+		_ = server.Close()
+		return exitCode(err)
+	case <-stop:
+	}
+
+	cancelSchedules()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+	defer cancel()
+	_ = server.Shutdown(ctx)
+
+	return terminate(cmd, exited, cfg.timeout)
+}
+
+// terminate sends SIGTERM to the function and waits for it to exit gracefully,
+// it's killed if still running after timeout.
+func terminate(cmd *exec.Cmd, exited <-chan error, timeout time.Duration) int {
+	_ = cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case err := <-exited:
+		return exitCode(err)
+	case <-time.After(timeout):
+		log.Printf("Function did not exit in %v, killing it.", timeout)
+		_ = cmd.Process.Kill()
+		return exitCode(<-exited)
+	}
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// initialize waits for the function to listen, then calls /v1/initialize.
+func (e *emulator) initialize(exited <-chan error) error {
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", e.target.Host, time.Second)
+		if err == nil {
+			_ = conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("function is not listening on %s", e.target.Host)
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("function exited, %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	rq, _ := http.NewRequest(http.MethodPost, e.target.String()+"/v1/initialize", nil)
+	rq.Header.Set("X-Faas-Internal-Request", "true")
+	rq.Header.Set("X-Faas-Internal-Token", e.internalToken)
+	e.setPlatformHeaders(rq.Header)
+
+	start := time.Now()
+	resp, err := e.client.Do(rq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("initializer responded with status %d", resp.StatusCode)
+	}
+	log.Printf("Function initialized in %v.", time.Since(start).Round(time.Millisecond))
+
+	return nil
+}
+
+func (e *emulator) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, eventsPathPrefix) {
+		if r.Method != http.MethodPost {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		e.serveEvent(rw, r, strings.TrimPrefix(r.URL.Path, eventsPathPrefix))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), e.cfg.timeout)
+	defer cancel()
+	e.proxy.ServeHTTP(rw, r.WithContext(ctx))
+}
+
+// direct rewrites local traffic as http trigger requests.
+func (e *emulator) direct(r *http.Request) {
+	r.URL.Scheme = e.target.Scheme
+	r.URL.Host = e.target.Host
+
+	// Callers must not impersonate the platform.
+	for k := range r.Header {
+		if strings.HasPrefix(http.CanonicalHeaderKey(k), "X-Faas-") {
+			r.Header.Del(k)
+		}
+	}
+	if host, port, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		r.Header.Set("X-Real-Ip", host)
+		r.Header.Set("X-Real-Port", port)
+	}
+	r.Header.Set("X-Faas-Event-Type", events.EventTypeHTTP)
+	e.setPlatformHeaders(r.Header)
+}
+
+// serveEvent fires the registered event name and relays the function response.
+func (e *emulator) serveEvent(rw http.ResponseWriter, r *http.Request, name string) {
+	resp, err := e.fire(r.Context(), name)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, v := range resp.Header {
+		rw.Header()[k] = v
+	}
+	rw.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(rw, resp.Body)
+}
+
+func (e *emulator) runSchedule(ctx context.Context, name string, s schedule) {
+	for {
+		next := s.next(time.Now())
+		if next.IsZero() {
+			log.Printf("Schedule of event %q never fires.", name)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		resp, err := e.fire(ctx, name)
+		if err != nil {
+			log.Printf("Failed to fire event %q, %v.", name, err)
+			continue
+		}
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if code := resp.Header.Get("X-Faas-Response-Error-Code"); code != "" {
+			log.Printf("Fired event %q, function responded with status %d, error code %s.", name, resp.StatusCode, code)
+		} else {
+			log.Printf("Fired event %q, function responded with status %d.", name, resp.StatusCode)
+		}
+	}
+}
+
+// fire sends the registered event name to the function in binary mode.
+func (e *emulator) fire(ctx context.Context, name string) (*http.Response, error) {
+	path, ok := e.cfg.events[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event %q", name)
+	}
+	event, err := loadEvent(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rq, err := events.NewBinaryRequest(e.target.String()+"/", event)
+	if err != nil {
+		return nil, err
+	}
+	e.setPlatformHeaders(rq.Header)
+
+	return e.client.Do(rq.WithContext(ctx))
+}
+
+// setPlatformHeaders sets the request id and the fake sts credentials.
+func (e *emulator) setPlatformHeaders(h http.Header) {
+	h.Set("X-Faas-Request-Id", newId())
+	h.Set("X-Faas-Access-Key-Id", e.cfg.accessKeyId)
+	h.Set("X-Faas-Secret-Access-Key", e.cfg.secretAccessKey)
+	h.Set("X-Faas-Session-Token", e.cfg.sessionToken)
+}
+
+// loadEvent reads a structured mode CloudEvent from the json file at path, the
+// missing id, time and specversion are filled.
+func loadEvent(path string) (*events.CloudEvent, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("invalid event file %s, %v", path, err)
+	}
+	if _, ok := fields["specversion"]; !ok {
+		fields["specversion"] = cloudevents.VersionV1
+	}
+	if _, ok := fields["id"]; !ok {
+		fields["id"] = newId()
+	}
+	if _, ok := fields["time"]; !ok {
+		fields["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	if raw, err = json.Marshal(fields); err != nil {
+		return nil, err
+	}
+
+	event := cloudevents.NewEvent()
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return nil, fmt.Errorf("invalid event file %s, %v", path, err)
+	}
+	if err := event.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid event file %s, %v", path, err)
+	}
+
+	return &events.CloudEvent{Event: &event}, nil
+}
+
+func newId() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command vefaas-local runs a vefaas function binary locally the way the veFaaS
+// platform drives it.
+//
+// It launches the function with the _FAAS_* environment variables, calls the
+// initializer through /v1/initialize, forwards the http traffic of the local
+// listener to the function as http trigger requests, and fires CloudEvents read
+// from json files, on demand or on a schedule. The function receives SIGTERM
+// when vefaas-local exits.
+//
+// Usage:
+//
+//	vefaas-local [flags] -- ./main [args...]
+//
+// Events are registered with -event name=file.json, where the file holds a
+// structured mode CloudEvent, like:
+//
+//	{"specversion": "1.0", "type": "faas.timer.event", "source": "timer/local", "data": {...}}
+//
+// A registered event is fired by "POST /_vefaas-local/events/<name>" on the local
+// listener, or on a schedule with -schedule name="*/5 * * * *" (cron syntax, or
+// "@every 30s"). The id and time of the event are generated on every firing if
+// absent in the file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// keyValueFlags is a repeatable flag of name=value pairs.
+type keyValueFlags map[string]string
+
+func (f keyValueFlags) String() string {
+	pairs := make([]string, 0, len(f))
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f keyValueFlags) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+		return fmt.Errorf("expected name=value, but got %q", s)
+	}
+	f[kv[0]] = kv[1]
+	return nil
+}
+
+func main() {
+	log.SetFlags(log.LstdFlags)
+	log.SetPrefix("vefaas-local: ")
+
+	cfg := config{
+		events:    make(keyValueFlags),
+		schedules: make(keyValueFlags),
+	}
+	flag.StringVar(&cfg.listen, "listen", ":8000", "local address serving http traffic and on demand events")
+	flag.IntVar(&cfg.port, "port", 5000, "runtime port of the function, passed as _FAAS_RUNTIME_PORT")
+	flag.DurationVar(&cfg.timeout, "timeout", 900*time.Second, "function timeout, passed as _FAAS_FUNC_TIMEOUT")
+	flag.StringVar(&cfg.name, "name", "local-function", "function name, passed as _FAAS_FUNC_NAME")
+	flag.StringVar(&cfg.accessKeyId, "access-key-id", "AKLTlocal", "access key id of the fake sts credentials")
+	flag.StringVar(&cfg.secretAccessKey, "secret-access-key", "local-secret-access-key", "secret access key of the fake sts credentials")
+	flag.StringVar(&cfg.sessionToken, "session-token", "local-session-token", "session token of the fake sts credentials")
+	flag.Var(cfg.events, "event", "register a CloudEvent as name=file.json, repeatable")
+	flag.Var(cfg.schedules, "schedule", `fire a registered event on a schedule as name="cron spec", repeatable`)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] -- ./main [args...]\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg.command = flag.Args()
+	if len(cfg.command) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	os.Exit(run(cfg))
+}
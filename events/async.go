@@ -0,0 +1,45 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package events
+
+// The CloudEvent types of the results of asynchronous invocations, delivered to
+// the on-success and on-failure destinations.
+const (
+	FaasAsyncSuccessEvent = "faas.async.success"
+	FaasAsyncFailureEvent = "faas.async.failure"
+)
+
+// AsyncInvocationResult is the data of the CloudEvents reporting the results of
+// asynchronous invocations.
+type AsyncInvocationResult struct {
+	// RequestId is the id of the invocation request.
+	RequestId string `json:"request_id"`
+
+	// StatusCode, Headers and Body are the response of the invocation.
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       []byte            `json:"body,omitempty"`
+
+	// ErrorCode and ErrorMessage describe the error of a failed invocation, like
+	// function_execution_error.
+	ErrorCode    string `json:"error_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// Failed reports whether the invocation failed.
+func (r *AsyncInvocationResult) Failed() bool {
+	return r.ErrorCode != "" || r.StatusCode >= 400
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package events
+
+import (
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// CloudEventBatchReport reports the results of the events of a batch, which are
+// dispatched to a handler of single events one by one.
+type CloudEventBatchReport struct {
+	Results []CloudEventResult `json:"results"`
+}
+
+// CloudEventResult is the result of handling an event of a batch.
+type CloudEventResult struct {
+	// ID and Source identify the event.
+	ID     string `json:"id"`
+	Source string `json:"source"`
+
+	// StatusCode is the status code of the handler response, or the error.
+	StatusCode int `json:"status_code"`
+
+	// ErrorCode and ErrorMessage describe the error of a failed event, like
+	// function_execution_error.
+	ErrorCode    string `json:"error_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+
+	// Retryable and Terminal tell whether the failed event should be retried,
+	// both false if the handler didn't say. RetryAfter is the delay in seconds
+	// before retrying, if any.
+	Retryable  bool `json:"retryable,omitempty"`
+	Terminal   bool `json:"terminal,omitempty"`
+	RetryAfter int  `json:"retry_after,omitempty"`
+
+	// Reply is the CloudEvent replied by the handler of a succeeded event, like
+	// with vefaas.CloudEventResponse, otherwise Body is the response body, which
+	// is base64 encoded in json. Neither is kept for failed events.
+	Reply *cloudevents.Event `json:"reply,omitempty"`
+	Body  []byte             `json:"body,omitempty"`
+}
+
+// Failed reports whether handling the event failed.
+func (r *CloudEventResult) Failed() bool {
+	return r.ErrorCode != "" || r.StatusCode >= 400
+}
+
+// Failed returns the results of the failed events.
+func (r *CloudEventBatchReport) Failed() []CloudEventResult {
+	var failed []CloudEventResult
+	for _, result := range r.Results {
+		if result.Failed() {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// BatchItemFailureReport reports the failed messages of a message queue trigger
+// event, so that only those are redelivered.
+type BatchItemFailureReport struct {
+	BatchItemFailures []BatchItemFailure `json:"batch_item_failures"`
+}
+
+// BatchItemFailure identifies a failed message of a message queue trigger event.
+type BatchItemFailure struct {
+	// Topic, Partition and Offset locate the message, Partition and Offset are
+	// the queue id and queue offset for rocketmq messages.
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+
+	// MessageId is the id of rocketmq messages.
+	MessageId string `json:"message_id,omitempty"`
+
+	// ErrorCode and ErrorMessage describe the error, see CloudEventResult for
+	// Retryable, Terminal and RetryAfter.
+	ErrorCode    string `json:"error_code"`
+	ErrorMessage string `json:"error_message,omitempty"`
+	Retryable    bool   `json:"retryable,omitempty"`
+	Terminal     bool   `json:"terminal,omitempty"`
+	RetryAfter   int    `json:"retry_after,omitempty"`
+}
@@ -0,0 +1,239 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+// The builders below create events shaped like the ones sent by vefaas triggers,
+// with the data types of this package, which are useful for testing handlers in
+// process, or encoded as http requests with NewBinaryRequest and
+// NewStructuredRequest. They are not guaranteed to be identical to the events of
+// the platform.
+
+// NewCloudEvent creates a CloudEvent of eventType from source, with data encoded
+// as json. It panics if data can not be encoded.
+func NewCloudEvent(eventType, source string, data interface{}) *CloudEvent {
+	event := cloudevents.NewEvent()
+	event.SetID(newEventID())
+	event.SetType(eventType)
+	event.SetSource(source)
+	event.SetTime(time.Now())
+	if data != nil {
+		if err := event.SetData(cloudevents.ApplicationJSON, data); err != nil {
+			panic(fmt.Sprintf("events: failed to encode event data, %v", err))
+		}
+	}
+
+	return &CloudEvent{Event: &event}
+}
+
+// NewTimerEvent creates a timer trigger event fired now.
+func NewTimerEvent(triggerName, payload string) *CloudEvent {
+	data := TimerEventData{
+		TriggerName: triggerName,
+		TriggerTime: time.Now().UTC().Format(time.RFC3339),
+		Payload:     payload,
+	}
+
+	return NewCloudEvent(FaasTimerEvent, "timer/"+triggerName, data)
+}
+
+// NewKafkaEvent creates a kafka trigger event carrying messages of topic. The
+// message values are given in order, at partition 0 starting from offset 0.
+func NewKafkaEvent(topic string, values ...[]byte) *CloudEvent {
+	return NewCloudEvent(FaasKafkaEvent, "kafka/"+topic, KafkaEventData{Messages: kafkaMessages(topic, values)})
+}
+
+// NewBmqEvent creates a bmq trigger event carrying messages of topic, see NewKafkaEvent.
+func NewBmqEvent(topic string, values ...[]byte) *CloudEvent {
+	return NewCloudEvent(FaasBmqEvent, "bmq/"+topic, BmqEventData{Messages: kafkaMessages(topic, values)})
+}
+
+// NewRocketMqEvent creates a rocketmq trigger event carrying messages of topic.
+// The message bodies are given in order, at queue 0 starting from offset 0.
+func NewRocketMqEvent(topic string, bodies ...[]byte) *CloudEvent {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	messages := make([]RocketMqMessage, 0, len(bodies))
+	for i, body := range bodies {
+		messages = append(messages, RocketMqMessage{
+			Topic:         topic,
+			MsgId:         strings.ToUpper(newEventID()),
+			QueueOffset:   int64(i),
+			Body:          body,
+			BornTimestamp: now,
+		})
+	}
+
+	return NewCloudEvent(FaasRocketMqEvent, "rocketmq/"+topic, RocketMqEventData{Messages: messages})
+}
+
+// NewTosEvent creates a tos trigger event for the operation on the object key of
+// bucket, op is like "tos:ObjectCreated:Put" or "tos:ObjectRemoved:Delete".
+func NewTosEvent(bucket, key, op string) *CloudEvent {
+	record := TosEventRecord{
+		EventName:    op,
+		EventSource:  "tos",
+		EventTime:    time.Now().UTC().Format(time.RFC3339),
+		EventVersion: "1.0",
+	}
+	record.Tos.Bucket.Name = bucket
+	record.Tos.Object.Key = key
+
+	event := NewCloudEvent(FaasTosEvent, "tos/"+bucket, TosEventData{Events: []TosEventRecord{record}})
+	event.SetSubject(key)
+
+	return event
+}
+
+// NewSnsEvent creates a sns trigger event publishing message to topic.
+func NewSnsEvent(topic, message string) *CloudEvent {
+	data := SnsEventData{Topic: topic, MessageId: newEventID(), Message: message}
+
+	return NewCloudEvent(FaasSnsEvent, "sns/"+topic, data)
+}
+
+// NewTlsEvent creates a tls trigger event carrying logs of the topic in project.
+func NewTlsEvent(projectId, topicId string, logs ...map[string]string) *CloudEvent {
+	data := TlsEventData{ProjectId: projectId, TopicId: topicId, Logs: logs}
+
+	return NewCloudEvent(FaasTlsEvent, "tls/"+topicId, data)
+}
+
+// NewHTTPRequest creates an http trigger request, with the header and query
+// string maps initialized.
+func NewHTTPRequest(method, path string, body []byte) *HTTPRequest {
+	return &HTTPRequest{
+		HTTPMethod:            method,
+		Path:                  path,
+		RemoteAddr:            "127.0.0.1:0",
+		PathParameters:        make(map[string]string),
+		QueryStringParameters: make(map[string]string),
+		Headers:               make(map[string]string),
+		Body:                  body,
+	}
+}
+
+// NewBinaryRequest encodes event as a binary mode http request to target url, the way
+// vefaas triggers deliver events.
+func NewBinaryRequest(target string, event *CloudEvent) (*http.Request, error) {
+	return newCloudEventRequest(binding.WithForceBinary(context.Background()), target, event)
+}
+
+// NewStructuredRequest encodes event as a structured mode http request to target url.
+func NewStructuredRequest(target string, event *CloudEvent) (*http.Request, error) {
+	return newCloudEventRequest(binding.WithForceStructured(context.Background()), target, event)
+}
+
+// NewBatchRequest encodes batch in the application/cloudevents-batch+json
+// format as an http request to target url.
+func NewBatchRequest(target string, batch ...*CloudEvent) (*http.Request, error) {
+	list := make([]*cloudevents.Event, 0, len(batch))
+	for _, event := range batch {
+		if event == nil || event.Event == nil {
+			return nil, fmt.Errorf("events: nil cloudevent")
+		}
+		list = append(list, event.Event)
+	}
+	body, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+
+	rq, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	rq.Header.Set("Content-Type", cloudevents.ApplicationCloudEventsBatchJSON)
+	rq.Header.Set("X-Faas-Event-Type", EventTypeCloudEvent)
+
+	return rq, nil
+}
+
+// NewRequest encodes r as an http trigger request to the base url.
+func (r *HTTPRequest) NewRequest(baseURL string) (*http.Request, error) {
+	target := strings.TrimRight(baseURL, "/") + r.Path
+	if len(r.QueryStringParameters) > 0 {
+		query := url.Values{}
+		for k, v := range r.QueryStringParameters {
+			query.Set(k, v)
+		}
+		target += "?" + query.Encode()
+	}
+
+	rq, err := http.NewRequest(r.HTTPMethod, target, bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.Headers {
+		rq.Header.Set(k, v)
+	}
+	rq.Header.Set("X-Faas-Event-Type", EventTypeHTTP)
+
+	return rq, nil
+}
+
+func newCloudEventRequest(ctx context.Context, target string, event *CloudEvent) (*http.Request, error) {
+	if event == nil || event.Event == nil {
+		return nil, fmt.Errorf("events: nil cloudevent")
+	}
+	rq, err := http.NewRequest(http.MethodPost, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := cehttp.WriteRequest(ctx, binding.ToMessage(event.Event), rq); err != nil {
+		return nil, err
+	}
+	rq.Header.Set("X-Faas-Event-Type", EventTypeCloudEvent)
+
+	return rq, nil
+}
+
+func kafkaMessages(topic string, values [][]byte) []KafkaMessage {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	messages := make([]KafkaMessage, 0, len(values))
+	for i, value := range values {
+		messages = append(messages, KafkaMessage{
+			Topic:     topic,
+			Offset:    int64(i),
+			Value:     value,
+			Timestamp: now,
+		})
+	}
+
+	return messages
+}
+
+func newEventID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
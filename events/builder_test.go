@@ -0,0 +1,91 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+func TestCloudEventRequestRoundTrip(t *testing.T) {
+	encoders := map[string]func(string, *CloudEvent) (*http.Request, error){
+		"binary":     NewBinaryRequest,
+		"structured": NewStructuredRequest,
+	}
+	for mode, encode := range encoders {
+		t.Run(mode, func(t *testing.T) {
+			event := NewKafkaEvent("orders", []byte("order-1"), []byte("order-2"))
+			rq, err := encode("http://localhost:5000/", event)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if rq.Header.Get("X-Faas-Event-Type") != EventTypeCloudEvent {
+				t.Errorf("missing event type header")
+			}
+
+			decoded, err := binding.ToEvent(context.Background(), cehttp.NewMessageFromHttpRequest(rq))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if decoded.ID() != event.ID() || decoded.Type() != FaasKafkaEvent || decoded.Source() != "kafka/orders" {
+				t.Errorf("unexpected event %v", decoded)
+			}
+
+			var data KafkaEventData
+			if err := decoded.DataAs(&data); err != nil {
+				t.Fatalf("decode data: %v", err)
+			}
+			if len(data.Messages) != 2 || string(data.Messages[1].Value) != "order-2" || data.Messages[1].Offset != 1 {
+				t.Errorf("unexpected data %+v", data)
+			}
+		})
+	}
+}
+
+func TestTosEvent(t *testing.T) {
+	event := NewTosEvent("photos", "2022/cat.png", "tos:ObjectCreated:Put")
+
+	var data TosEventData
+	if err := event.DataAs(&data); err != nil {
+		t.Fatalf("decode data: %v", err)
+	}
+	if event.Subject() != "2022/cat.png" || len(data.Events) != 1 ||
+		data.Events[0].Tos.Bucket.Name != "photos" || data.Events[0].EventName != "tos:ObjectCreated:Put" {
+		t.Errorf("unexpected tos event %v", event)
+	}
+}
+
+func TestHTTPRequestNewRequest(t *testing.T) {
+	r := NewHTTPRequest(http.MethodPost, "/orders", []byte(`{"id":"1"}`))
+	r.QueryStringParameters["dry run"] = "true&false"
+	r.Headers["Content-Type"] = "application/json"
+
+	rq, err := r.NewRequest("http://localhost:5000/")
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if rq.URL.Path != "/orders" || rq.URL.Query().Get("dry run") != "true&false" {
+		t.Errorf("unexpected url %v", rq.URL)
+	}
+	if rq.Header.Get("X-Faas-Event-Type") != EventTypeHTTP || rq.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected header %v", rq.Header)
+	}
+}
@@ -16,6 +16,11 @@
 
 package events
 
+import (
+	"context"
+	"io"
+)
+
 // EventResponse represents the returned response from a vefaas-golang-runtime handler.
 type EventResponse struct {
 	// StatusCode is supposed to be a valid HTTP Status code
@@ -26,4 +31,10 @@ type EventResponse struct {
 
 	// Body just body, no surprise
 	Body []byte
+
+	// BodyStream, if set, streams the body instead of Body once the status code
+	// and headers are written, every write to w is flushed to the client right
+	// away. ctx is the invocation context, which is cancelled when the client
+	// disconnects or the function times out. See vefaas.SSE for an example.
+	BodyStream func(ctx context.Context, w io.Writer) error `json:"-"`
 }
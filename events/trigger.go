@@ -0,0 +1,123 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+// The data of the CloudEvents sent by vefaas triggers, which can be decoded with
+// CloudEvent.DataAs, like:
+//
+//	var data events.KafkaEventData
+//	if err := event.DataAs(&data); err != nil {
+//		return nil, err
+//	}
+//
+// The types model the payloads of the triggers for the handlers and the event
+// builders, they are not verified against the payloads sent by the platform, so
+// check the trigger documentation for the fields a handler relies on.
+
+// TimerEventData is the data of timer trigger events.
+type TimerEventData struct {
+	// TriggerName is the name of the timer trigger.
+	TriggerName string `json:"trigger_name"`
+
+	// TriggerTime is the scheduled time of this firing, in RFC 3339 format.
+	TriggerTime string `json:"trigger_time"`
+
+	// Payload is the user defined payload of the timer trigger.
+	Payload string `json:"payload,omitempty"`
+}
+
+// KafkaMessage is a message consumed by kafka or bmq triggers.
+type KafkaMessage struct {
+	Topic     string            `json:"topic"`
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Key       []byte            `json:"key,omitempty"`
+	Value     []byte            `json:"value"`
+	Timestamp int64             `json:"timestamp"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// KafkaEventData is the data of kafka trigger events, which carries a batch of
+// messages.
+type KafkaEventData struct {
+	Messages []KafkaMessage `json:"messages"`
+}
+
+// BmqEventData is the data of bmq trigger events, bmq is compatible with kafka.
+type BmqEventData = KafkaEventData
+
+// RocketMqMessage is a message consumed by rocketmq triggers.
+type RocketMqMessage struct {
+	Topic         string            `json:"topic"`
+	Tags          string            `json:"tags,omitempty"`
+	Keys          string            `json:"keys,omitempty"`
+	MsgId         string            `json:"msg_id"`
+	QueueId       int32             `json:"queue_id"`
+	QueueOffset   int64             `json:"queue_offset"`
+	Body          []byte            `json:"body"`
+	BornTimestamp int64             `json:"born_timestamp"`
+	Properties    map[string]string `json:"properties,omitempty"`
+}
+
+// RocketMqEventData is the data of rocketmq trigger events, which carries a batch
+// of messages.
+type RocketMqEventData struct {
+	Messages []RocketMqMessage `json:"messages"`
+}
+
+// TosEventData is the data of tos trigger events, following the tos bucket
+// notification format.
+type TosEventData struct {
+	Events []TosEventRecord `json:"events"`
+}
+
+// TosEventRecord is a single object notification of a tos bucket.
+type TosEventRecord struct {
+	EventName    string `json:"eventName"`
+	EventSource  string `json:"eventSource"`
+	EventTime    string `json:"eventTime"`
+	EventVersion string `json:"eventVersion"`
+	Region       string `json:"region"`
+	Tos          struct {
+		Bucket struct {
+			Name string `json:"name"`
+			Trn  string `json:"trn,omitempty"`
+		} `json:"bucket"`
+		Object struct {
+			Key  string `json:"key"`
+			Size int64  `json:"size"`
+			ETag string `json:"eTag,omitempty"`
+		} `json:"object"`
+	} `json:"tos"`
+}
+
+// SnsEventData is the data of sns trigger events.
+type SnsEventData struct {
+	Topic      string            `json:"topic"`
+	MessageId  string            `json:"message_id"`
+	Subject    string            `json:"subject,omitempty"`
+	Message    string            `json:"message"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// TlsEventData is the data of tls (log service) trigger events, which carries a
+// batch of logs.
+type TlsEventData struct {
+	ProjectId string              `json:"project_id"`
+	TopicId   string              `json:"topic_id"`
+	Logs      []map[string]string `json:"logs"`
+}
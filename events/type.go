@@ -31,4 +31,13 @@ const (
 
 	// EventTypeCloudEvent represents trigger events (timer/kafka/rocketmq/tos/abase_binlog, etc)
 	EventTypeCloudEvent = "cloudevent"
+
+	// EventTypeWebSocket represents WebSocket connections upgraded from the http
+	// requests of HTTP triggers, which carry EventTypeHTTP in the X-Faas-Event-Type
+	// header.
+	EventTypeWebSocket = "websocket"
+
+	// EventTypeCloudEventBatch represents batches of trigger events, delivered as
+	// EventTypeCloudEvent requests in the application/cloudevents-batch+json format.
+	EventTypeCloudEventBatch = "cloudevent-batch"
 )
@@ -2,4 +2,8 @@ module github.com/volcengine/vefaas-golang-runtime
 
 go 1.16
 
-require github.com/cloudevents/sdk-go/v2 v2.6.0
+require (
+	github.com/cloudevents/sdk-go/v2 v2.6.0
+	github.com/volcengine/volcengine-go-sdk v1.1.35
+	golang.org/x/net v0.0.0-20220722155237-a158d28d115b
+)
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+// FileStore is a Store keeping every record in a file of its directory, which
+// can be shared by the instances of a function on a network file system. The
+// expired records are removed when their keys are acquired again, under the
+// lock file of the key, so an expired record is taken over by one invocation.
+// The lock files are left in the directory.
+type FileStore struct {
+	dir string
+}
+
+// fileRecord is the file format of Record.
+type fileRecord struct {
+	Response    *events.EventResponse `json:"response,omitempty"`
+	Fingerprint string                `json:"fingerprint,omitempty"`
+	ExpiresAt   time.Time             `json:"expires_at"`
+}
+
+// NewFileStore creates a FileStore keeping the records in dir, which is created
+// if missing.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+// Acquire implements Store. The record file is created exclusively, so a key
+// is only acquired by one invocation at a time.
+func (s *FileStore) Acquire(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	path := s.path(key)
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			err = json.NewEncoder(f).Encode(&fileRecord{Fingerprint: fingerprint, ExpiresAt: time.Now().Add(ttl)})
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				_ = os.Remove(path)
+				return nil, false, err
+			}
+			return nil, true, nil
+		}
+		if !os.IsExist(err) {
+			return nil, false, err
+		}
+
+		record, err := s.read(path)
+		if err != nil {
+			return nil, false, err
+		}
+		if record == nil {
+			// Released in the meantime.
+			continue
+		}
+		if time.Now().Before(record.ExpiresAt) {
+			return record, false, nil
+		}
+		if err := s.removeExpired(path); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Lost the race to another invocation acquiring the key.
+	return &Record{ExpiresAt: time.Now().Add(ttl)}, false, nil
+}
+
+// Complete implements Store. The record file is replaced atomically.
+func (s *FileStore) Complete(ctx context.Context, key string, resp *events.EventResponse, ttl time.Duration) error {
+	var fingerprint string
+	if record, err := s.read(s.path(key)); err == nil && record != nil {
+		fingerprint = record.Fingerprint
+	}
+	data, err := json.Marshal(&fileRecord{Response: resp, Fingerprint: fingerprint, ExpiresAt: time.Now().Add(ttl)})
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(s.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// Release implements Store.
+func (s *FileStore) Release(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// removeExpired removes the record file at path if it's still expired, as the
+// record may have been taken over by another invocation in the meantime.
+func (s *FileStore) removeExpired(path string) error {
+	unlock, err := lockFile(strings.TrimSuffix(path, ".json") + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	record, err := s.read(path)
+	if err != nil || record == nil || time.Now().Before(record.ExpiresAt) {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// read reads the record file at path, it returns nil if the file is missing.
+func (s *FileStore) read(path string) (*Record, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var r fileRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		// The record is being written by the invocation acquiring the key.
+		return &Record{ExpiresAt: time.Now().Add(time.Second)}, nil
+	}
+	return &Record{Response: r.Response, Fingerprint: r.Fingerprint, ExpiresAt: r.ExpiresAt}, nil
+}
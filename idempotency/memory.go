@@ -0,0 +1,146 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package idempotency
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+// DefaultMemoryStoreCapacity is the capacity of the MemoryStore created with a
+// non-positive capacity.
+const DefaultMemoryStoreCapacity = 10000
+
+// ErrStoreFull is returned by MemoryStore.Acquire when its capacity is taken by
+// the records in progress.
+var ErrStoreFull = errors.New("idempotency store is full")
+
+// MemoryStore is a Store keeping the records in memory, evicting the least
+// recently used completed ones beyond its capacity. The records in progress are
+// never evicted, Acquire fails with ErrStoreFull once they fill the capacity.
+// The records are only shared by the invocations of the same function instance.
+type MemoryStore struct {
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type memoryEntry struct {
+	key    string
+	record Record
+}
+
+// NewMemoryStore creates a MemoryStore keeping at most capacity records.
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = DefaultMemoryStoreCapacity
+	}
+
+	return &MemoryStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Acquire implements Store.
+func (s *MemoryStore) Acquire(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if e, ok := s.entries[key]; ok {
+		entry := e.Value.(*memoryEntry)
+		if now.Before(entry.record.ExpiresAt) {
+			s.lru.MoveToFront(e)
+			record := entry.record
+			return &record, false, nil
+		}
+		s.remove(e)
+	}
+
+	if !s.put(key, Record{Fingerprint: fingerprint, ExpiresAt: now.Add(ttl)}) {
+		return nil, false, ErrStoreFull
+	}
+	return nil, true, nil
+}
+
+// Complete implements Store.
+func (s *MemoryStore) Complete(ctx context.Context, key string, resp *events.EventResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fingerprint string
+	if e, ok := s.entries[key]; ok {
+		fingerprint = e.Value.(*memoryEntry).record.Fingerprint
+		s.remove(e)
+	}
+	// The response is not kept if the capacity is taken by records in progress.
+	s.put(key, Record{Response: resp, Fingerprint: fingerprint, ExpiresAt: time.Now().Add(ttl)})
+	return nil
+}
+
+// Release implements Store.
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		s.remove(e)
+	}
+	return nil
+}
+
+// Len returns the number of records, including the expired ones not evicted yet.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lru.Len()
+}
+
+// put stores record of key, evicting the least recently used records not in
+// progress, or expired, beyond the capacity. It reports false without storing
+// record if there is no room for it.
+func (s *MemoryStore) put(key string, record Record) bool {
+	now := time.Now()
+	e := s.lru.Back()
+	for s.lru.Len() >= s.capacity && e != nil {
+		prev := e.Prev()
+		if r := e.Value.(*memoryEntry).record; !r.InProgress() || !now.Before(r.ExpiresAt) {
+			s.remove(e)
+		}
+		e = prev
+	}
+	if s.lru.Len() >= s.capacity {
+		return false
+	}
+
+	s.entries[key] = s.lru.PushFront(&memoryEntry{key: key, record: record})
+	return true
+}
+
+func (s *MemoryStore) remove(e *list.Element) {
+	s.lru.Remove(e)
+	delete(s.entries, e.Value.(*memoryEntry).key)
+}
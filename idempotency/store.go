@@ -0,0 +1,62 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package idempotency provides the stores of the idempotency records of
+// invocations, used by vefaas.WithIdempotency to suppress duplicate events and
+// retried requests.
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+// Record is the idempotency record of a key.
+type Record struct {
+	// Response is the response of the completed invocation, nil if the
+	// invocation is still in progress.
+	Response *events.EventResponse
+
+	// Fingerprint identifies the request of the invocation, like the digest of
+	// its body, so a key reused by a different request can be told. It's empty
+	// if not known.
+	Fingerprint string
+
+	// ExpiresAt is when the record expires.
+	ExpiresAt time.Time
+}
+
+// InProgress reports whether the invocation of the record is still in progress.
+func (r *Record) InProgress() bool {
+	return r.Response == nil
+}
+
+// Store stores the idempotency records, implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Acquire claims key for an invocation of the request identified by
+	// fingerprint until ttl elapses, if key has no record yet or its record has
+	// expired. Otherwise, the record of key is returned with acquired false.
+	Acquire(ctx context.Context, key, fingerprint string, ttl time.Duration) (record *Record, acquired bool, err error)
+
+	// Complete stores resp as the result of the invocation holding key, which
+	// is kept for ttl along with the fingerprint given to Acquire.
+	Complete(ctx context.Context, key string, resp *events.EventResponse, ttl time.Duration) error
+
+	// Release removes the record of key, so the invocation can be retried.
+	Release(ctx context.Context, key string) error
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	if _, acquired, err := s.Acquire(ctx, "a", "digest", time.Minute); err != nil || !acquired {
+		t.Fatalf("Acquire() = %v, %v, want acquired", acquired, err)
+	}
+	record, acquired, err := s.Acquire(ctx, "a", "", time.Minute)
+	if err != nil || acquired || !record.InProgress() || record.Fingerprint != "digest" {
+		t.Fatalf("Acquire() in progress = %+v, %v, %v", record, acquired, err)
+	}
+
+	resp := &events.EventResponse{StatusCode: 201, Headers: map[string]string{"K": "v"}, Body: []byte("done")}
+	if err := s.Complete(ctx, "a", resp, time.Minute); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	record, acquired, err = s.Acquire(ctx, "a", "", time.Minute)
+	if err != nil || acquired || record.InProgress() || record.Fingerprint != "digest" ||
+		record.Response.StatusCode != 201 || string(record.Response.Body) != "done" || record.Response.Headers["K"] != "v" {
+		t.Fatalf("Acquire() completed = %+v, %v, %v", record, acquired, err)
+	}
+
+	if err := s.Release(ctx, "a"); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, acquired, err := s.Acquire(ctx, "a", "", time.Minute); err != nil || !acquired {
+		t.Fatalf("Acquire() after release = %v, %v, want acquired", acquired, err)
+	}
+	if err := s.Release(ctx, "missing"); err != nil {
+		t.Errorf("Release() missing error = %v", err)
+	}
+
+	// Expired records are acquired again.
+	if _, acquired, _ := s.Acquire(ctx, "b", "", time.Millisecond); !acquired {
+		t.Fatal("Acquire() not acquired")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, acquired, err := s.Acquire(ctx, "b", "", time.Minute); err != nil || !acquired {
+		t.Errorf("Acquire() expired = %v, %v, want acquired", acquired, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore(0))
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(2)
+	for _, key := range []string{"a", "b", "c"} {
+		_, _, _ = s.Acquire(ctx, key, "", time.Minute)
+		_ = s.Complete(ctx, key, &events.EventResponse{}, time.Minute)
+	}
+	if s.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", s.Len())
+	}
+	if _, acquired, _ := s.Acquire(ctx, "a", "", time.Minute); !acquired {
+		t.Error("least recently used record not evicted")
+	}
+	if _, acquired, _ := s.Acquire(ctx, "c", "", time.Minute); acquired {
+		t.Error("recently used record evicted")
+	}
+
+	// The records in progress are never evicted.
+	s = NewMemoryStore(2)
+	for _, key := range []string{"a", "b"} {
+		_, _, _ = s.Acquire(ctx, key, "", time.Minute)
+	}
+	if _, _, err := s.Acquire(ctx, "c", "", time.Minute); err != ErrStoreFull {
+		t.Errorf("Acquire() error = %v, want %v", err, ErrStoreFull)
+	}
+	if record, acquired, _ := s.Acquire(ctx, "a", "", time.Minute); acquired || !record.InProgress() {
+		t.Error("record in progress evicted")
+	}
+	if err := s.Complete(ctx, "a", &events.EventResponse{}, time.Minute); err != nil {
+		t.Errorf("Complete() error = %v", err)
+	}
+	if _, acquired, err := s.Acquire(ctx, "c", "", time.Minute); err != nil || !acquired {
+		t.Errorf("Acquire() = %v, %v, want the completed record evicted", acquired, err)
+	}
+	if record, acquired, _ := s.Acquire(ctx, "b", "", time.Minute); acquired || !record.InProgress() {
+		t.Error("record in progress evicted")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	testStore(t, s)
+}
+
+func TestFileStoreAcquireExpired(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+	ctx := context.Background()
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if _, acquired, _ := s.Acquire(ctx, key, "", time.Millisecond); !acquired {
+			t.Fatal("Acquire() not acquired")
+		}
+		time.Sleep(2 * time.Millisecond)
+
+		var wg sync.WaitGroup
+		var acquired int32
+		start := make(chan struct{})
+		for j := 0; j < 32; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				_, ok, err := s.Acquire(ctx, key, "", time.Minute)
+				if err != nil {
+					t.Errorf("Acquire() error = %v", err)
+				}
+				if ok {
+					atomic.AddInt32(&acquired, 1)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		if acquired != 1 {
+			t.Fatalf("expired %s acquired %d times, want once", key, acquired)
+		}
+	}
+}
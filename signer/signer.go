@@ -0,0 +1,237 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package signer signs requests to Volcengine OpenAPI with the temporary
+// credentials of the function, following the HMAC-SHA256 signature version 4
+// of Volcengine.
+//
+// Most functions only need Transport, which signs every outgoing request with
+// the credentials of the invocation carried by the request context:
+//
+//	client := &http.Client{Transport: signer.NewTransport("vefaas", "cn-beijing", nil)}
+//	rq, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://open.volcengineapi.com/?Action=ListFunctions&Version=2021-03-03", nil)
+//	resp, err := client.Do(rq)
+package signer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+)
+
+const (
+	// Algorithm is the signature algorithm in the Authorization header.
+	Algorithm = "HMAC-SHA256"
+
+	// DateFormat is the format of the X-Date header.
+	DateFormat = "20060102T150405Z"
+
+	DateHeader          = "X-Date"
+	ContentSha256Header = "X-Content-Sha256"
+	SecurityTokenHeader = "X-Security-Token"
+
+	defaultContentType = "application/x-www-form-urlencoded; charset=utf-8"
+)
+
+// ErrInvalidCredentials is returned when signing without the access key id or
+// the secret access key.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Signer signs requests for a Volcengine service in a region.
+type Signer struct {
+	Service string
+	Region  string
+
+	// now returns the signing time, it's overridden in tests.
+	now func() time.Time
+}
+
+// NewSigner creates a Signer of service in region, like "vefaas" in "cn-beijing".
+func NewSigner(service, region string) *Signer {
+	return &Signer{Service: service, Region: region}
+}
+
+// Sign signs rq in place with c, setting the Authorization, X-Date,
+// X-Content-Sha256 and, for temporary credentials, the X-Security-Token
+// headers. The query is normalized, and the body is read and replaced so it can
+// still be sent. A preset X-Date header is kept as the signing time.
+func (s *Signer) Sign(rq *http.Request, c vefaascontext.Credentials) error {
+	if !c.IsValid() {
+		return ErrInvalidCredentials
+	}
+
+	payload, err := readAndReplaceBody(rq)
+	if err != nil {
+		return err
+	}
+
+	if rq.URL.Path == "" {
+		rq.URL.Path = "/"
+	}
+	rq.URL.RawQuery = rq.URL.Query().Encode()
+	if rq.Header.Get("Content-Type") == "" {
+		rq.Header.Set("Content-Type", defaultContentType)
+	}
+	if rq.Header.Get(DateHeader) == "" {
+		rq.Header.Set(DateHeader, s.timeNow().UTC().Format(DateFormat))
+	}
+	if c.SessionToken != "" {
+		rq.Header.Set(SecurityTokenHeader, c.SessionToken)
+	}
+	payloadHash := hashSHA256(payload)
+	rq.Header.Set(ContentSha256Header, payloadHash)
+
+	host := rq.Host
+	if host == "" {
+		host = rq.URL.Host
+	}
+	rq.Header.Set("Host", host)
+
+	canonicalHeaders, signedHeaders := canonicalHeaders(rq.Header)
+	canonicalRequest := strings.Join([]string{
+		rq.Method,
+		canonicalPath(rq.URL.Path),
+		canonicalQuery(rq.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := rq.Header.Get(DateHeader)
+	if len(date) < 8 {
+		return errors.New("invalid " + DateHeader + " header " + date)
+	}
+	scope := strings.Join([]string{date[:8], s.Region, s.Service, "request"}, "/")
+	stringToSign := strings.Join([]string{Algorithm, date, scope, hashSHA256([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte(c.SecretAccessKey), date[:8])
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	rq.Header.Set("Authorization", Algorithm+
+		" Credential="+c.AccessKeyId+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+signature)
+
+	return nil
+}
+
+func (s *Signer) timeNow() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// canonicalHeaders returns the canonical headers and the signed header list,
+// which are Content-Type, Content-Md5, Host and all X- headers.
+func canonicalHeaders(h http.Header) (string, string) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		switch k {
+		case "Content-Type", "Content-Md5", "Host":
+		default:
+			if !strings.HasPrefix(k, "X-") {
+				continue
+			}
+		}
+		keys = append(keys, strings.ToLower(k))
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		v := strings.TrimSpace(h.Get(k))
+		if k == "host" {
+			if host, port, err := net.SplitHostPort(v); err == nil && (port == "80" || port == "443") {
+				v = host
+			}
+		}
+		b.WriteString(k + ":" + v + "\n")
+	}
+
+	return b.String(), strings.Join(keys, ";")
+}
+
+// canonicalPath escapes every byte of the path segments except the unreserved
+// characters.
+func canonicalPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		var b strings.Builder
+		for j := 0; j < len(segment); j++ {
+			c := segment[j]
+			if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+				c == '-' || c == '_' || c == '.' || c == '~' {
+				b.WriteByte(c)
+				continue
+			}
+			b.WriteByte('%')
+			b.WriteByte("0123456789ABCDEF"[c>>4])
+			b.WriteByte("0123456789ABCDEF"[c&15])
+		}
+		segments[i] = b.String()
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(v url.Values) string {
+	return strings.Replace(v.Encode(), "+", "%20", -1)
+}
+
+func readAndReplaceBody(rq *http.Request) ([]byte, error) {
+	if rq.Body == nil || rq.Body == http.NoBody {
+		return []byte{}, nil
+	}
+	payload, err := ioutil.ReadAll(rq.Body)
+	_ = rq.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	rq.Body = ioutil.NopCloser(bytes.NewReader(payload))
+	rq.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(payload)), nil
+	}
+	rq.ContentLength = int64(len(payload))
+
+	return payload, nil
+}
+
+func hmacSHA256(key []byte, content string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
+
+func hashSHA256(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
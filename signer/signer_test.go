@@ -0,0 +1,172 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signer
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+)
+
+var testCredentials = vefaascontext.Credentials{
+	AccessKeyId:     "AKLTexample",
+	SecretAccessKey: "c2VjcmV0LWtleQ==",
+}
+
+// The expected values are produced by the signer of the official Volcengine SDK.
+func TestSignKnownAnswers(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		body          string
+		contentType   string
+		sessionToken  string
+		rawQuery      string
+		contentSha256 string
+		authorization string
+	}{
+		{
+			name:          "get",
+			method:        http.MethodGet,
+			url:           "https://open.volcengineapi.com/?Action=ListFunctions&Version=2021-03-03",
+			rawQuery:      "Action=ListFunctions&Version=2021-03-03",
+			contentSha256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			authorization: "HMAC-SHA256 Credential=AKLTexample/20220615/cn-beijing/vefaas/request, SignedHeaders=content-type;host;x-content-sha256;x-date, Signature=4b3cb52589c62cad031112aac9d5f6097b0d44d547fe1060b203bd7553da387b",
+		},
+		{
+			name:          "post with session token",
+			method:        http.MethodPost,
+			url:           "https://open.volcengineapi.com/?Action=CreateFunction&Version=2021-03-03",
+			body:          `{"Name":"hello"}`,
+			contentType:   "application/json",
+			sessionToken:  "STSeyJMVE1P",
+			rawQuery:      "Action=CreateFunction&Version=2021-03-03",
+			contentSha256: "728a3386a0e64bff874c8d2e69234d45492d658f692b14e5a5c51c260e1aa56e",
+			authorization: "HMAC-SHA256 Credential=AKLTexample/20220615/cn-beijing/vefaas/request, SignedHeaders=content-type;host;x-content-sha256;x-date;x-security-token, Signature=6c1faca99ba12bf0a585b50c5c2f949cf84f83f413b6c8b006ee070e5fadd8e0",
+		},
+		{
+			name:          "escaped path and query with port",
+			method:        http.MethodPut,
+			url:           "http://example.volcengineapi.com:8080/a b/c~d?z=1&a=x y&a=2",
+			body:          "payload",
+			contentType:   "text/plain",
+			rawQuery:      "a=x+y&a=2&z=1",
+			contentSha256: "239f59ed55e737c77147cf55ad0c1b030b6d7ee748a7426952f9b852d5a935e5",
+			authorization: "HMAC-SHA256 Credential=AKLTexample/20220615/cn-beijing/vefaas/request, SignedHeaders=content-type;host;x-content-sha256;x-date, Signature=95e3033bba7d778bc158151be0d5f5053385b3fa0f9fe651f4e9d05c43fef668",
+		},
+	}
+
+	s := NewSigner("vefaas", "cn-beijing")
+	s.now = func() time.Time { return time.Date(2022, 6, 15, 8, 9, 10, 0, time.UTC) }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rq, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				rq.Header.Set("Content-Type", tt.contentType)
+			}
+			c := testCredentials
+			c.SessionToken = tt.sessionToken
+
+			if err := s.Sign(rq, c); err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			if got := rq.URL.RawQuery; got != tt.rawQuery {
+				t.Errorf("RawQuery = %q, want %q", got, tt.rawQuery)
+			}
+			if got := rq.Header.Get(ContentSha256Header); got != tt.contentSha256 {
+				t.Errorf("X-Content-Sha256 = %q, want %q", got, tt.contentSha256)
+			}
+			if got := rq.Header.Get("Authorization"); got != tt.authorization {
+				t.Errorf("Authorization = %q, want %q", got, tt.authorization)
+			}
+			if got := rq.Header.Get(SecurityTokenHeader); got != tt.sessionToken {
+				t.Errorf("X-Security-Token = %q, want %q", got, tt.sessionToken)
+			}
+			if body, _ := ioutil.ReadAll(rq.Body); string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestSignInvalidCredentials(t *testing.T) {
+	rq, _ := http.NewRequest(http.MethodGet, "https://open.volcengineapi.com/", nil)
+	if err := NewSigner("vefaas", "cn-beijing").Sign(rq, vefaascontext.Credentials{}); err != ErrInvalidCredentials {
+		t.Errorf("Sign() error = %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestTransportSignsWithInvocationCredentials(t *testing.T) {
+	var got http.Header
+	var gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		body, _ := ioutil.ReadAll(r.Body)
+		gotBody = string(body)
+	}))
+	defer server.Close()
+
+	invocation := httptest.NewRequest(http.MethodPost, "/", nil)
+	invocation.Header.Set("X-Faas-Access-Key-Id", "AKLTinvocation")
+	invocation.Header.Set("X-Faas-Secret-Access-Key", "secret")
+	invocation.Header.Set("X-Faas-Session-Token", "token")
+	ctx := vefaascontext.WithCredentialsContext(context.Background(), invocation)
+
+	client := &http.Client{Transport: NewTransport("vefaas", "cn-beijing", nil)}
+	rq, _ := http.NewRequest(http.MethodPost, server.URL+"/?Action=Ping", strings.NewReader("ping"))
+	resp, err := client.Do(rq.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if auth := got.Get("Authorization"); !strings.HasPrefix(auth, "HMAC-SHA256 Credential=AKLTinvocation/") {
+		t.Errorf("Authorization = %q, want signed with invocation credentials", auth)
+	}
+	if token := got.Get(SecurityTokenHeader); token != "token" {
+		t.Errorf("X-Security-Token = %q, want %q", token, "token")
+	}
+	if gotBody != "ping" {
+		t.Errorf("body = %q, want %q", gotBody, "ping")
+	}
+	if rq.Header.Get("Authorization") != "" {
+		t.Error("original request is modified")
+	}
+}
+
+func TestTransportWithoutCredentials(t *testing.T) {
+	transport := NewTransport("vefaas", "cn-beijing", nil)
+	transport.Credentials = func(*http.Request) vefaascontext.Credentials {
+		return vefaascontext.Credentials{}
+	}
+	client := &http.Client{Transport: transport}
+	_, err := client.Get("http://127.0.0.1/?Action=Ping")
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Get() error = %v, want %v", err, ErrInvalidCredentials)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signer
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+)
+
+// Transport is an http.RoundTripper signing every request with the temporary
+// credentials of the function.
+type Transport struct {
+	Signer *Signer
+
+	// Base is the underlying RoundTripper, http.DefaultTransport if nil.
+	Base http.RoundTripper
+
+	// Credentials returns the credentials to sign rq with, defaults to the
+	// credentials of the invocation in the request context, or the latest
+	// credentials of the process if the context carries none.
+	Credentials func(rq *http.Request) vefaascontext.Credentials
+}
+
+// NewTransport creates a Transport signing requests for service in region on
+// top of base.
+func NewTransport(service, region string, base http.RoundTripper) *Transport {
+	return &Transport{Signer: NewSigner(service, region), Base: base}
+}
+
+// RoundTrip signs a copy of rq, the original request is not modified.
+func (t *Transport) RoundTrip(rq *http.Request) (*http.Response, error) {
+	c := t.credentials(rq)
+	if !c.IsValid() {
+		closeBody(rq)
+		return nil, fmt.Errorf("sign request: %w", ErrInvalidCredentials)
+	}
+
+	signed := rq.Clone(rq.Context())
+	if err := t.Signer.Sign(signed, c); err != nil {
+		closeBody(rq)
+		return nil, fmt.Errorf("sign request: %w", err)
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(signed)
+}
+
+func (t *Transport) credentials(rq *http.Request) vefaascontext.Credentials {
+	if t.Credentials != nil {
+		return t.Credentials(rq)
+	}
+	if c := vefaascontext.CredentialsFromContext(rq.Context()); c.IsValid() {
+		return c
+	}
+	return vefaascontext.LatestCredentials()
+}
+
+func closeBody(rq *http.Request) {
+	if rq.Body != nil {
+		_ = rq.Body.Close()
+	}
+}
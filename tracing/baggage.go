@@ -0,0 +1,48 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// BaggageHeader is the W3C baggage header, see https://www.w3.org/TR/baggage/.
+const BaggageHeader = "Baggage"
+
+type baggageContextKey struct{}
+
+// ContextWithBaggage returns a copy of ctx carrying the W3C baggage value, which
+// is propagated as is to the downstream calls.
+func ContextWithBaggage(ctx context.Context, baggage string) context.Context {
+	return context.WithValue(ctx, baggageContextKey{}, strings.TrimSpace(baggage))
+}
+
+// BaggageFromContext retrieves the W3C baggage value from ctx.
+func BaggageFromContext(ctx context.Context) (baggage string) {
+	if ctx != nil {
+		baggage, _ = ctx.Value(baggageContextKey{}).(string)
+	}
+
+	return
+}
+
+// BaggageFromHeader returns the W3C baggage value of h, multiple headers are
+// combined into a single list.
+func BaggageFromHeader(h http.Header) string {
+	return strings.Join(h.Values(BaggageHeader), ",")
+}
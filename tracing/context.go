@@ -0,0 +1,156 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// W3C trace context headers, see https://www.w3.org/TR/trace-context/.
+const (
+	TraceparentHeader = "Traceparent"
+	TracestateHeader  = "Tracestate"
+)
+
+// TraceID is a unique identity of a trace.
+type TraceID [16]byte
+
+// IsValid reports whether the trace id is not all zero.
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// String returns the lower case hex encoding of the trace id.
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID is a unique identity of a span in a trace.
+type SpanID [8]byte
+
+// IsValid reports whether the span id is not all zero.
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// String returns the lower case hex encoding of the span id.
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// FlagsSampled is the sampled bit of the W3C trace flags.
+const FlagsSampled byte = 0x01
+
+// SpanContext contains the identifying trace information about a span.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	TraceFlags byte
+	TraceState string
+
+	// Remote reports whether the span context was propagated from a remote parent.
+	Remote bool
+}
+
+// IsValid reports whether the span context has both valid trace id and span id.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// IsSampled reports whether the sampled flag is set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.TraceFlags&FlagsSampled == FlagsSampled
+}
+
+// Traceparent formats the span context as a W3C traceparent value.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.TraceFlags)
+}
+
+// ParseTraceparent parses the W3C traceparent and tracestate values into a remote
+// span context. The returned bool is false if traceparent is malformed.
+func ParseTraceparent(traceparent, tracestate string) (SpanContext, bool) {
+	sc := SpanContext{}
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 {
+		return sc, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// Version ff is forbidden, and version 00 must have exactly four fields.
+	if len(version) != 2 || version == "ff" || (version == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if _, err := hex.DecodeString(version); err != nil {
+		return sc, false
+	}
+	if len(traceID) != 32 || len(spanID) != 16 || len(flags) != 2 {
+		return sc, false
+	}
+	if strings.ToLower(traceID) != traceID || strings.ToLower(spanID) != spanID {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(traceID)); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(spanID)); err != nil {
+		return sc, false
+	}
+	var f [1]byte
+	if _, err := hex.Decode(f[:], []byte(flags)); err != nil {
+		return sc, false
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.TraceFlags = f[0]
+	sc.TraceState = strings.TrimSpace(tracestate)
+	sc.Remote = true
+
+	return sc, true
+}
+
+// SpanContextFromHeader extracts the remote span context from W3C trace context headers.
+func SpanContextFromHeader(h http.Header) (SpanContext, bool) {
+	return ParseTraceparent(h.Get(TraceparentHeader), h.Get(TracestateHeader))
+}
+
+// InjectHeader writes sc into h as W3C trace context headers.
+func InjectHeader(sc SpanContext, h http.Header) {
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+func newTraceID() (id TraceID) {
+	_, _ = rand.Read(id[:])
+	return
+}
+
+func newSpanID() (id SpanID) {
+	_, _ = rand.Read(id[:])
+	return
+}
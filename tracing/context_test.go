@@ -0,0 +1,55 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import "testing"
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		name        string
+		traceparent string
+		ok          bool
+	}{
+		{"valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"future version with extra field", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-abc", true},
+		{"empty", "", false},
+		{"forbidden version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"version 00 with extra field", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-abc", false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"zero span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"upper case", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"short trace id", "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false},
+		{"not hex", "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(c.traceparent, "vendor=value")
+			if ok != c.ok {
+				t.Fatalf("ParseTraceparent(%q) ok = %v, want %v", c.traceparent, ok, c.ok)
+			}
+			if !ok {
+				return
+			}
+			if !sc.Remote || !sc.IsSampled() || sc.TraceState != "vendor=value" {
+				t.Errorf("unexpected span context %+v", sc)
+			}
+			if c.name == "valid" && sc.Traceparent() != c.traceparent {
+				t.Errorf("Traceparent() = %q, want %q", sc.Traceparent(), c.traceparent)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/version"
+)
+
+const (
+	instrumentationScope = "github.com/volcengine/vefaas-golang-runtime"
+	defaultServiceName   = "vefaas-function"
+)
+
+// OTLPHTTPExporter exports spans to an OpenTelemetry collector with the OTLP/HTTP
+// protocol, using the JSON encoding.
+type OTLPHTTPExporter struct {
+	// Endpoint is the full url spans are posted to, like http://localhost:4318/v1/traces.
+	Endpoint string
+
+	// Headers are sent along with every export request, like authentication headers.
+	Headers map[string]string
+
+	// Resource describes the entity producing the spans, service.name is expected.
+	Resource map[string]interface{}
+
+	// Client is used to send export requests, http.DefaultClient is used if nil.
+	Client *http.Client
+}
+
+// NewOTLPHTTPExporter creates an OTLP/HTTP exporter posting spans to endpoint, with
+// the service name taken from the environment.
+func NewOTLPHTTPExporter(endpoint string) *OTLPHTTPExporter {
+	return &OTLPHTTPExporter{
+		Endpoint: endpoint,
+		Headers:  make(map[string]string),
+		Resource: map[string]interface{}{
+			"service.name": serviceNameFromEnv(),
+		},
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewOTLPHTTPExporterFromEnv creates an OTLP/HTTP exporter configured with the
+// standard OpenTelemetry environment variables:
+//
+// - OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, the full url spans are posted to, or
+// OTEL_EXPORTER_OTLP_ENDPOINT, the base url which /v1/traces is appended to.
+//
+// - OTEL_EXPORTER_OTLP_TRACES_HEADERS or OTEL_EXPORTER_OTLP_HEADERS, a list of
+// comma separated key=value pairs sent as request headers.
+//
+// It returns nil if no endpoint is configured.
+func NewOTLPHTTPExporterFromEnv() *OTLPHTTPExporter {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimRight(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return nil
+	}
+
+	e := NewOTLPHTTPExporter(endpoint)
+	headers := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")
+	if headers == "" {
+		headers = os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	}
+	for _, pair := range strings.Split(headers, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			continue
+		}
+		e.Headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return e
+}
+
+// ExportSpans posts spans to the collector.
+func (e *OTLPHTTPExporter) ExportSpans(ctx context.Context, spans []*SpanData) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("otlp collector responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	return nil
+}
+
+// Shutdown does nothing, the exporter holds no resources.
+func (e *OTLPHTTPExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// The types below mirror the JSON encoding of the OTLP ExportTraceServiceRequest.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes,omitempty"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		TraceState        string         `json:"traceState,omitempty"`
+		Name              string         `json:"name"`
+		Kind              SpanKind       `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Events            []otlpEvent    `json:"events,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+
+	otlpEvent struct {
+		TimeUnixNano string         `json:"timeUnixNano"`
+		Name         string         `json:"name"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	}
+
+	otlpStatus struct {
+		Code    StatusCode `json:"code,omitempty"`
+		Message string     `json:"message,omitempty"`
+	}
+
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+
+	otlpAnyValue struct {
+		StringValue *string         `json:"stringValue,omitempty"`
+		BoolValue   *bool           `json:"boolValue,omitempty"`
+		IntValue    *string         `json:"intValue,omitempty"`
+		DoubleValue *float64        `json:"doubleValue,omitempty"`
+		ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+	}
+
+	otlpArrayValue struct {
+		Values []otlpAnyValue `json:"values"`
+	}
+)
+
+func (e *OTLPHTTPExporter) request(spans []*SpanData) otlpRequest {
+	scopeSpans := otlpScopeSpans{
+		Scope: otlpScope{Name: instrumentationScope, Version: version.Version},
+		Spans: make([]otlpSpan, 0, len(spans)),
+	}
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.SpanContext.TraceID.String(),
+			SpanID:            s.SpanContext.SpanID.String(),
+			TraceState:        s.SpanContext.TraceState,
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+			Status:            otlpStatus{Code: s.StatusCode, Message: s.StatusMessage},
+		}
+		if s.Parent.IsValid() {
+			span.ParentSpanID = s.Parent.SpanID.String()
+		}
+		for _, ev := range s.Events {
+			span.Events = append(span.Events, otlpEvent{
+				TimeUnixNano: strconv.FormatInt(ev.Time.UnixNano(), 10),
+				Name:         ev.Name,
+				Attributes:   otlpAttributes(ev.Attributes),
+			})
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, span)
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: otlpAttributes(e.Resource)},
+			ScopeSpans: []otlpScopeSpans{scopeSpans},
+		}},
+	}
+}
+
+func otlpAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	kvs := make([]otlpKeyValue, 0, len(attributes))
+	for k, v := range attributes {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: otlpValue(v)})
+	}
+
+	return kvs
+}
+
+func otlpValue(v interface{}) otlpAnyValue {
+	switch val := v.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &val}
+	case bool:
+		return otlpAnyValue{BoolValue: &val}
+	case int:
+		s := strconv.Itoa(val)
+		return otlpAnyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		return otlpAnyValue{IntValue: &s}
+	case float64:
+		return otlpAnyValue{DoubleValue: &val}
+	case []string:
+		values := make([]otlpAnyValue, 0, len(val))
+		for _, s := range val {
+			values = append(values, otlpValue(s))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	default:
+		s := fmt.Sprintf("%v", val)
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func serviceNameFromEnv() string {
+	for _, key := range []string{"OTEL_SERVICE_NAME", "_FAAS_FUNC_NAME"} {
+		if s := os.Getenv(key); s != "" {
+			return s
+		}
+	}
+
+	return defaultServiceName
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SpanKind describes the relationship between a span and its parent and children.
+// The values follow the OTLP span kind enumeration.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+	SpanKindProducer SpanKind = 4
+	SpanKindConsumer SpanKind = 5
+)
+
+// StatusCode is the status of a finished span. The values follow the OTLP status
+// code enumeration.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Event is a time-stamped annotation of a span.
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]interface{}
+}
+
+// SpanData is the immutable snapshot of an ended span handed over to exporters.
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	Parent        SpanContext
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    map[string]interface{}
+	Events        []Event
+	StatusCode    StatusCode
+	StatusMessage string
+}
+
+// Span is a single operation within a trace. All the methods are safe to call on
+// a nil span, so that callers do not need to check whether tracing is enabled.
+type Span struct {
+	tracer *Tracer
+
+	mu   sync.Mutex
+	data SpanData
+	done bool
+}
+
+// SpanContext returns the span context of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data.SpanContext
+}
+
+// SetParent links the span to a remote parent discovered after the span was
+// started, like the distributed tracing extension of a structured CloudEvent.
+// It has no effect if the span already has a valid parent, or has ended.
+func (s *Span) SetParent(parent SpanContext) {
+	if s == nil || !parent.IsValid() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done || s.data.Parent.IsValid() {
+		return
+	}
+	s.data.Parent = parent
+	s.data.SpanContext.TraceID = parent.TraceID
+	s.data.SpanContext.TraceFlags = parent.TraceFlags
+	s.data.SpanContext.TraceState = parent.TraceState
+}
+
+// SetName overrides the name of the span.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Name = name
+}
+
+// SetAttribute sets an attribute on the span. Supported value types are string,
+// bool, int, int64, float64 and []string, other types are formatted as string.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return
+	}
+	s.data.Attributes[key] = value
+}
+
+// AddEvent adds an event to the span.
+func (s *Span) AddEvent(name string, attributes map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return
+	}
+	s.data.Events = append(s.data.Events, Event{Name: name, Time: time.Now(), Attributes: attributes})
+}
+
+// SetStatus sets the status of the span. An error status is never overridden by
+// an ok status.
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done || (s.data.StatusCode == StatusError && code != StatusError) {
+		return
+	}
+	s.data.StatusCode = code
+	s.data.StatusMessage = message
+}
+
+// RecordError records err as an exception event and marks the span as failed.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.AddEvent("exception", map[string]interface{}{
+		"exception.type":    fmt.Sprintf("%T", err),
+		"exception.message": err.Error(),
+	})
+	s.SetStatus(StatusError, err.Error())
+}
+
+// RecordPanic records a recovered panic value and its stack trace as an exception
+// event and marks the span as failed.
+func (s *Span) RecordPanic(value interface{}, stack []byte) {
+	if s == nil {
+		return
+	}
+	message := fmt.Sprintf("%v", value)
+	s.AddEvent("exception", map[string]interface{}{
+		"exception.type":       fmt.Sprintf("%T", value),
+		"exception.message":    message,
+		"exception.stacktrace": string(stack),
+		"exception.escaped":    true,
+	})
+	s.SetStatus(StatusError, "panic: "+message)
+}
+
+// End completes the span and hands it over to the exporter of its tracer. Calls
+// after the first one are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+	s.done = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.tracer != nil && data.SpanContext.IsSampled() {
+		s.tracer.enqueue(&data)
+	}
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext retrieves the current span from ctx, it returns nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+
+	return span
+}
+
+// StartSpan starts a child span of the current span in ctx, which is exported with
+// the same tracer. If ctx carries no span, ctx is returned as is along with a nil
+// span, on which all the methods are no-op.
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	return parent.tracer.Start(ctx, name, kind, parent.SpanContext())
+}
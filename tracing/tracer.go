@@ -0,0 +1,177 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchSize     = 512
+	defaultFlushInterval = 5 * time.Second
+)
+
+// Exporter sends ended spans to a tracing backend.
+type Exporter interface {
+	// ExportSpans exports a batch of spans.
+	ExportSpans(ctx context.Context, spans []*SpanData) error
+
+	// Shutdown releases the resources held by the exporter.
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer starts spans and exports the ended ones in batches with its exporter.
+//
+// A nil tracer is valid, spans started with it are propagated but never exported.
+type Tracer struct {
+	exporter Exporter
+
+	mu      sync.Mutex
+	pending []*SpanData
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+// NewTracer creates a tracer exporting spans with exporter. It returns nil if
+// exporter is nil.
+func NewTracer(exporter Exporter) *Tracer {
+	if exporter == nil {
+		return nil
+	}
+
+	t := &Tracer{
+		exporter: exporter,
+		flushCh:  make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	go t.loop()
+
+	return t
+}
+
+// Start starts a span with the given parent and stores it into the returned context.
+// A new trace is started if parent is not valid.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, parent SpanContext) (context.Context, *Span) {
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.TraceFlags = parent.TraceFlags
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+		sc.TraceFlags = FlagsSampled
+	}
+
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:        name,
+			Kind:        kind,
+			SpanContext: sc,
+			Parent:      parent,
+			StartTime:   time.Now(),
+			Attributes:  make(map[string]interface{}),
+		},
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// ForceFlush exports all the pending spans immediately.
+func (t *Tracer) ForceFlush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	return t.export(ctx)
+}
+
+// Shutdown flushes the pending spans and shuts down the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	select {
+	case <-t.stopCh:
+		return nil
+	default:
+		close(t.stopCh)
+	}
+	<-t.doneCh
+
+	if err := t.export(ctx); err != nil {
+		_ = t.exporter.Shutdown(ctx)
+		return err
+	}
+
+	return t.exporter.Shutdown(ctx)
+}
+
+func (t *Tracer) enqueue(data *SpanData) {
+	t.mu.Lock()
+	t.pending = append(t.pending, data)
+	full := len(t.pending) >= defaultBatchSize
+	t.mu.Unlock()
+
+	if full {
+		select {
+		case t.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) loop() {
+	defer close(t.doneCh)
+
+	ticker := time.NewTicker(defaultFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stopCh:
+			return
+		case <-ticker.C:
+		case <-t.flushCh:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), defaultFlushInterval)
+		if err := t.export(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export spans, %v.\n", err)
+		}
+		cancel()
+	}
+}
+
+func (t *Tracer) export(ctx context.Context) error {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	return t.exporter.ExportSpans(ctx, spans)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DebugHeader is set to "true" on the responses of the requests handled in debug
+// mode, in which case error responses carry DebugInfo.
+const DebugHeader = "X-Faas-Debug"
+
+const maxStackFrames = 32
+
+// DebugInfo is the diagnostic information of an error, which is only rendered in
+// debug mode.
+type DebugInfo struct {
+	// Panic is the recovered panic value.
+	Panic string `json:"panic,omitempty"`
+
+	// Stack is the trimmed stack trace of the panic, one frame per element.
+	Stack []string `json:"stack,omitempty"`
+
+	// Errors is the error chain, from the outermost error to the innermost cause.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// PanicDebugInfo creates the DebugInfo of a recovered panic value and the stack
+// trace returned by debug.Stack.
+func PanicDebugInfo(v interface{}, stack []byte) *DebugInfo {
+	info := &DebugInfo{
+		Panic: fmt.Sprintf("%v", v),
+		Stack: TrimStack(stack),
+	}
+	if err, ok := v.(error); ok {
+		info.Errors = ErrorChain(err)
+	}
+
+	return info
+}
+
+// ErrorDebugInfo creates the DebugInfo of an error returned from function handler.
+func ErrorDebugInfo(err error) *DebugInfo {
+	return &DebugInfo{Errors: ErrorChain(err)}
+}
+
+// ErrorChain unwraps err and returns the type and message of every error in the
+// chain, from the outermost error to the innermost cause.
+func ErrorChain(err error) []string {
+	var chain []string
+	for ; err != nil && len(chain) < maxStackFrames; err = errors.Unwrap(err) {
+		chain = append(chain, fmt.Sprintf("%T: %v", err, err))
+	}
+
+	return chain
+}
+
+// TrimStack trims the stack trace returned by debug.Stack to the frames of the
+// panicking code, which start after the panic call and end before the frames of
+// the runtime event handlers or net/http. Each frame is formatted as "function (file:line)".
+func TrimStack(stack []byte) []string {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "goroutine ") {
+		lines = lines[1:]
+	}
+
+	// Every frame takes two lines, the function and the file position.
+	type frame struct{ function, position string }
+	var frames []frame
+	for i := 0; i+1 < len(lines); i += 2 {
+		function := strings.TrimSpace(lines[i])
+		if j := strings.LastIndex(function, "("); j > 0 {
+			function = function[:j]
+		}
+		position := strings.TrimSpace(lines[i+1])
+		if j := strings.LastIndex(position, " +0x"); j > 0 {
+			position = position[:j]
+		}
+		frames = append(frames, frame{function, position})
+	}
+
+	// Skip the frames of debug.Stack and the deferred recover functions.
+	for i := len(frames) - 1; i >= 0; i-- {
+		if frames[i].function == "panic" {
+			frames = frames[i+1:]
+			break
+		}
+	}
+
+	trimmed := make([]string, 0, len(frames))
+	for _, f := range frames {
+		if strings.HasPrefix(f.function, "github.com/volcengine/vefaas-golang-runtime/vefaas.handle") ||
+			strings.HasPrefix(f.function, "net/http.") || len(trimmed) >= maxStackFrames {
+			break
+		}
+		trimmed = append(trimmed, fmt.Sprintf("%s (%s)", f.function, f.position))
+	}
+
+	return trimmed
+}
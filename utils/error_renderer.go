@@ -0,0 +1,177 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrorResponse describes an error response generated by the runtime, or returned
+// from function handler as vefaas.Error.
+type ErrorResponse struct {
+	// StatusCode is the http status code of the response.
+	StatusCode int
+
+	// Code is the machine readable error code, like function_execution_error.
+	Code string
+
+	// Message is the human readable error message.
+	Message string
+
+	// RequestId is the id of the failed request.
+	RequestId string
+
+	// Details is optional additional information of the error.
+	Details interface{}
+
+	// Retryable reports whether the caller may retry the request.
+	Retryable bool
+
+	// Debug is the diagnostic information of the error, which is dropped unless
+	// the request is handled in debug mode.
+	Debug *DebugInfo
+}
+
+// ErrorRenderer renders the body of an error response, and returns its content
+// type along with the body.
+type ErrorRenderer func(e *ErrorResponse) (contentType string, body []byte)
+
+// problemDetails is the RFC 7807 problem details object, extended with the
+// vefaas error information.
+type problemDetails struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Code      string      `json:"code"`
+	RequestId string      `json:"request_id,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+	Retryable bool        `json:"retryable"`
+	Debug     *DebugInfo  `json:"debug,omitempty"`
+}
+
+// ProblemJSONErrorRenderer renders errors as RFC 7807 application/problem+json,
+// it's the default error renderer.
+func ProblemJSONErrorRenderer(e *ErrorResponse) (string, []byte) {
+	problem := problemDetails{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.StatusCode),
+		Status:    e.StatusCode,
+		Detail:    e.Message,
+		Code:      e.Code,
+		RequestId: e.RequestId,
+		Details:   e.Details,
+		Retryable: e.Retryable,
+		Debug:     e.Debug,
+	}
+	body, err := json.Marshal(problem)
+	if err != nil {
+		// Details can not be encoded, render the error without it.
+		problem.Details = nil
+		body, _ = json.Marshal(problem)
+	}
+
+	return "application/problem+json", body
+}
+
+// PlainTextErrorRenderer renders errors as text/plain, with the error code and
+// message on the first line, followed by the request id if present.
+func PlainTextErrorRenderer(e *ErrorResponse) (string, []byte) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n", e.Code, e.Message)
+	if e.RequestId != "" {
+		fmt.Fprintf(&b, "request id: %s\n", e.RequestId)
+	}
+	if e.Debug != nil {
+		if e.Debug.Panic != "" {
+			fmt.Fprintf(&b, "\npanic: %s\n", e.Debug.Panic)
+		}
+		for _, frame := range e.Debug.Stack {
+			fmt.Fprintf(&b, "\t%s\n", frame)
+		}
+		if len(e.Debug.Errors) > 0 {
+			b.WriteString("\nerrors:\n")
+		}
+		for _, err := range e.Debug.Errors {
+			fmt.Fprintf(&b, "\t%s\n", err)
+		}
+	}
+
+	return "text/plain; charset=utf-8", []byte(b.String())
+}
+
+// errorRenderingWriter is implemented by the http.ResponseWriter of invocations,
+// which carries the error renderer configured for the function, and whether the
+// invocation is handled in debug mode.
+type errorRenderingWriter interface {
+	ErrorRenderer() ErrorRenderer
+	DebugEnabled() bool
+}
+
+// errorRenderingOf returns how to render the error responses written to rw, by
+// the writer it wraps if not rw itself. The default is ProblemJSONErrorRenderer
+// without debug information.
+func errorRenderingOf(rw http.ResponseWriter) (renderer ErrorRenderer, debug bool) {
+	for rw != nil {
+		if w, ok := rw.(errorRenderingWriter); ok {
+			renderer, debug = w.ErrorRenderer(), w.DebugEnabled()
+			break
+		}
+		u, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		rw = u.Unwrap()
+	}
+	if renderer == nil {
+		renderer = ProblemJSONErrorRenderer
+	}
+	return renderer, debug
+}
+
+// WriteErrorResponse writes e with X-Faas-Response-Error-* headers and a body
+// rendered by the error renderer carried by rw, see vefaas.WithErrorRenderer.
+// The request id is taken from the X-Faas-Request-Id response header if not
+// set, and the debug information is dropped unless rw tells the invocation is
+// handled in debug mode, the response headers are never trusted for it.
+func WriteErrorResponse(rw http.ResponseWriter, e *ErrorResponse) {
+	if e.RequestId == "" {
+		e.RequestId = rw.Header().Get("X-Faas-Request-Id")
+	}
+	renderer, debug := errorRenderingOf(rw)
+	if !debug {
+		e.Debug = nil
+	}
+
+	rw.Header().Set("X-Faas-Response-Error-Code", e.Code)
+	rw.Header().Set("X-Faas-Response-Error-Message", e.Message)
+
+	contentType, body := renderer(e)
+	if contentType != "" {
+		rw.Header().Set("Content-Type", contentType)
+	}
+	if len(body) > 0 {
+		rw.Header().Del("Content-Length")
+	}
+	rw.WriteHeader(e.StatusCode)
+	if len(body) > 0 {
+		_, _ = rw.Write(body)
+	}
+}
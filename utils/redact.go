@@ -0,0 +1,93 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"net/http"
+)
+
+// RedactedValue replaces the values of sensitive headers.
+const RedactedValue = "[REDACTED]"
+
+// platformHeaders are the platform headers carrying the temporary credentials
+// of the function, or meant for the runtime only.
+var platformHeaders = map[string]bool{
+	"X-Faas-Access-Key-Id":          true,
+	"X-Faas-Secret-Access-Key":      true,
+	"X-Faas-Session-Token":          true,
+	"X-Faas-Credentials-Expiration": true,
+	"X-Faas-Internal-Request":       true,
+	"X-Faas-Internal-Token":         true,
+	"X-Faas-Debug":                  true,
+}
+
+// IsPlatformHeader reports whether the header name, in any case, is a platform
+// header carrying credentials or meant for the runtime only, which is stripped
+// from the request passed to function handler.
+func IsPlatformHeader(name string) bool {
+	return platformHeaders[http.CanonicalHeaderKey(name)]
+}
+
+// IsSensitiveHeader reports whether the header name, in any case, carries
+// credentials and must never be logged. Besides the platform headers, these are
+// Authorization and X-Security-Token.
+func IsSensitiveHeader(name string) bool {
+	switch name = http.CanonicalHeaderKey(name); name {
+	case "Authorization", "X-Security-Token":
+		return true
+	default:
+		return platformHeaders[name]
+	}
+}
+
+// StripPlatformHeaders deletes the platform headers from headers in place.
+func StripPlatformHeaders(headers map[string]string) {
+	for k := range headers {
+		if IsPlatformHeader(k) {
+			delete(headers, k)
+		}
+	}
+}
+
+// RedactHeaders returns a copy of h with the values of sensitive headers
+// replaced by RedactedValue.
+func RedactHeaders(h http.Header) http.Header {
+	redacted := make(http.Header, len(h))
+	for k, v := range h {
+		if IsSensitiveHeader(k) {
+			redacted[k] = []string{RedactedValue}
+			continue
+		}
+		redacted[k] = append([]string(nil), v...)
+	}
+
+	return redacted
+}
+
+// RedactHeaderMap returns a copy of headers, like events.HTTPRequest.Headers,
+// with the values of sensitive headers replaced by RedactedValue.
+func RedactHeaderMap(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if IsSensitiveHeader(k) {
+			v = RedactedValue
+		}
+		redacted[k] = v
+	}
+
+	return redacted
+}
@@ -0,0 +1,94 @@
+//go:build go1.21
+// +build go1.21
+
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// RedactingHandler is a slog.Handler masking the sensitive headers wherever
+// they appear in the attributes of records: as attributes named after them, or
+// in http.Header and map[string]string values.
+//
+//	logger := slog.New(utils.NewRedactingHandler(slog.NewJSONHandler(os.Stderr, nil)))
+//	logger.Info("request", "headers", req.Headers)
+type RedactingHandler struct {
+	next slog.Handler
+}
+
+// NewRedactingHandler wraps next with redaction of the sensitive headers.
+func NewRedactingHandler(next slog.Handler) *RedactingHandler {
+	return &RedactingHandler{next: next}
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(a))
+		return true
+	})
+
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+
+	return &RedactingHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	if IsSensitiveHeader(a.Key) {
+		return slog.String(a.Key, RedactedValue)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := v.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = redactAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		switch headers := v.Any().(type) {
+		case http.Header:
+			return slog.Any(a.Key, RedactHeaders(headers))
+		case map[string]string:
+			return slog.Any(a.Key, RedactHeaderMap(headers))
+		}
+	}
+
+	return slog.Attr{Key: a.Key, Value: v}
+}
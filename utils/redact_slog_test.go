@@ -0,0 +1,49 @@
+//go:build go1.21
+// +build go1.21
+
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package utils
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewRedactingHandler(slog.NewJSONHandler(&buf, nil)))
+
+	h := http.Header{}
+	h.Set("X-Faas-Session-Token", "header-token")
+	logger.With("X-Faas-Secret-Access-Key", "attr-secret").Info("request",
+		"headers", h,
+		slog.Group("req", "map", map[string]string{"X-Faas-Session-Token": "map-token"}),
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"header-token", "attr-secret", "map-token"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("%q leaked in %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, RedactedValue) {
+		t.Errorf("missing redacted value in %s", out)
+	}
+}
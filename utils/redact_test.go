@@ -0,0 +1,51 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package utils
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Faas-Session-Token", "token")
+	h.Set("x-faas-secret-access-key", "secret")
+	h.Set("X-Faas-Debug", "secret")
+	h.Set("Content-Type", "application/json")
+
+	redacted := RedactHeaders(h)
+	if got := redacted.Get("X-Faas-Session-Token"); got != RedactedValue {
+		t.Errorf("X-Faas-Session-Token = %q, want redacted", got)
+	}
+	if got := redacted.Get("X-Faas-Secret-Access-Key"); got != RedactedValue {
+		t.Errorf("X-Faas-Secret-Access-Key = %q, want redacted", got)
+	}
+	if got := redacted.Get("X-Faas-Debug"); got != RedactedValue {
+		t.Errorf("X-Faas-Debug = %q, want redacted", got)
+	}
+	if got := redacted.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want kept", got)
+	}
+	if h.Get("X-Faas-Session-Token") != "token" {
+		t.Error("original headers are modified")
+	}
+
+	m := RedactHeaderMap(map[string]string{"x-security-token": "token", "Accept": "*/*"})
+	if m["x-security-token"] != RedactedValue || m["Accept"] != "*/*" {
+		t.Errorf("unexpected redacted map %v", m)
+	}
+}
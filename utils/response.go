@@ -28,15 +28,7 @@ import (
 
 func RecoverFunc(rw http.ResponseWriter, callback func()) {
 	if err := recover(); err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "panic: %v\n%s", err, debug.Stack())
-		rw.Header().Set(
-			"X-Faas-Response-Error-Code", "function_panic",
-		)
-		rw.Header().Set(
-			"X-Faas-Response-Error-Message",
-			"Function panic, please check log for more details.",
-		)
-		rw.WriteHeader(http.StatusInternalServerError)
+		SetFunctionPanicHeader(rw, err, debug.Stack())
 
 		if callback != nil {
 			callback()
@@ -44,6 +36,16 @@ func RecoverFunc(rw http.ResponseWriter, callback func()) {
 	}
 }
 
+func SetFunctionPanicHeader(rw http.ResponseWriter, err interface{}, stack []byte) {
+	_, _ = fmt.Fprintf(os.Stderr, "panic: %v\n%s", err, stack)
+	WriteErrorResponse(rw, &ErrorResponse{
+		StatusCode: http.StatusInternalServerError,
+		Code:       "function_panic",
+		Message:    "Function panic, please check log for more details.",
+		Debug:      PanicDebugInfo(err, stack),
+	})
+}
+
 func RawBodyFromHttpRequest(r *http.Request) ([]byte, error) {
 	var rawBody bytes.Buffer
 	if r.Body != nil {
@@ -58,47 +60,44 @@ func RawBodyFromHttpRequest(r *http.Request) ([]byte, error) {
 }
 
 func SetInvalidCloudEventHeader(rw http.ResponseWriter, err error) {
-	rw.Header().Set(
-		"X-Faas-Response-Error-Code", "invalid_cloud_event",
-	)
-	rw.Header().Set(
-		"X-Faas-Response-Error-Message",
-		fmt.Sprintf(`The request is not valid cloudevent message, %v.`, err),
-	)
-	rw.WriteHeader(http.StatusBadRequest)
+	WriteErrorResponse(rw, &ErrorResponse{
+		StatusCode: http.StatusBadRequest,
+		Code:       "invalid_cloud_event",
+		Message:    fmt.Sprintf(`The request is not valid cloudevent message, %v.`, err),
+	})
+}
+
+func SetInvalidWebSocketUpgradeHeader(rw http.ResponseWriter, statusCode int, err error) {
+	WriteErrorResponse(rw, &ErrorResponse{
+		StatusCode: statusCode,
+		Code:       "invalid_websocket_upgrade",
+		Message:    fmt.Sprintf(`The request is not valid websocket upgrade request, %v.`, err),
+	})
 }
 
 func SetFunctionExecutionErrorHeader(rw http.ResponseWriter, err error) {
-	rw.Header().Set(
-		"X-Faas-Response-Error-Code", "function_execution_error",
-	)
-	rw.Header().Set(
-		"X-Faas-Response-Error-Message",
-		fmt.Sprintf(`Function returns error, %v.`, err),
-	)
-	rw.WriteHeader(http.StatusInternalServerError)
+	WriteErrorResponse(rw, &ErrorResponse{
+		StatusCode: http.StatusInternalServerError,
+		Code:       "function_execution_error",
+		Message:    fmt.Sprintf(`Function returns error, %v.`, err),
+		Debug:      ErrorDebugInfo(err),
+	})
 }
 
 func SetFunctionNoResponseErrorHeader(rw http.ResponseWriter) {
-	rw.Header().Set(
-		"X-Faas-Response-Error-Code", "function_no_response",
-	)
-	rw.Header().Set(
-		"X-Faas-Response-Error-Message",
-		"No response was returned from function.",
-	)
-	rw.WriteHeader(http.StatusInternalServerError)
+	WriteErrorResponse(rw, &ErrorResponse{
+		StatusCode: http.StatusInternalServerError,
+		Code:       "function_no_response",
+		Message:    "No response was returned from function.",
+	})
 }
 
 func SetInvalidEventTypeHeader(rw http.ResponseWriter, t string, expectedT ...string) {
-	rw.Header().Set(
-		"X-Faas-Response-Error-Code", "invalid_event_type",
-	)
-	rw.Header().Set(
-		"X-Faas-Response-Error-Message",
-		fmt.Sprintf(`The request event type "%s" is not acceptable, expected type "%v".`, t, expectedT),
-	)
-	rw.WriteHeader(http.StatusBadRequest)
+	WriteErrorResponse(rw, &ErrorResponse{
+		StatusCode: http.StatusBadRequest,
+		Code:       "invalid_event_type",
+		Message:    fmt.Sprintf(`The request event type "%s" is not acceptable, expected type "%v".`, t, expectedT),
+	})
 }
 
 func SetExecutionDurationHeader(rw http.ResponseWriter, startTime time.Time) {
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// adminHandler serves the internal and diagnostic endpoints on the admin
+// listener, which is trusted, so the internal requests need no authentication:
+//   - /v1/initialize and /v1/version, the same as the internal requests,
+//   - /healthz, 200 once initialized, 503 before that or when shutting down,
+//   - /metrics, the invocation metrics in Prometheus text format,
+//   - /debug/pprof/, the runtime profiles of package net/http/pprof.
+func (s *functionServer) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/initialize", s.serveInternal)
+	mux.HandleFunc("/v1/version", s.serveInternal)
+	mux.HandleFunc("/healthz", s.serveHealth)
+	mux.HandleFunc("/metrics", s.serveMetrics)
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return mux
+}
+
+func (s *functionServer) serveHealth(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	switch {
+	case atomic.LoadInt32(&s.shuttingDown) != 0:
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = rw.Write([]byte("shutting down\n"))
+	case !s.isInitialized():
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = rw.Write([]byte("not initialized\n"))
+	default:
+		_, _ = rw.Write([]byte("ok\n"))
+	}
+}
+
+func (s *functionServer) serveMetrics(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m := &s.metrics
+
+	initialized := 0
+	if s.isInitialized() {
+		initialized = 1
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	writeMetric(rw, "vefaas_invocations_total", "counter", "Total invocations handled.", atomic.LoadInt64(&m.total))
+	writeMetric(rw, "vefaas_invocation_errors_total", "counter", "Invocations responded with an error.", atomic.LoadInt64(&m.errors))
+	writeMetric(rw, "vefaas_invocations_in_flight", "gauge", "Invocations being handled.", atomic.LoadInt64(&m.inFlight))
+	writeMetric(rw, "vefaas_invocation_duration_seconds_sum", "counter", "Total duration of the invocations in seconds.",
+		time.Duration(atomic.LoadInt64(&m.durationNanos)).Seconds())
+	writeMetric(rw, "vefaas_initialized", "gauge", "Whether the function is initialized.", initialized)
+	writeMetric(rw, "go_goroutines", "gauge", "Number of goroutines that currently exist.", runtime.NumGoroutine())
+	writeMetric(rw, "go_memstats_heap_alloc_bytes", "gauge", "Number of heap bytes allocated and still in use.", mem.HeapAlloc)
+}
+
+func writeMetric(rw http.ResponseWriter, name, kind, help string, value interface{}) {
+	fmt.Fprintf(rw, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+}
+
+// invocationMetrics counts the invocations, the fields are accessed atomically.
+type invocationMetrics struct {
+	total         int64
+	errors        int64
+	inFlight      int64
+	durationNanos int64
+}
+
+func (m *invocationMetrics) start() time.Time {
+	atomic.AddInt64(&m.inFlight, 1)
+	return time.Now()
+}
+
+func (m *invocationMetrics) end(start time.Time, rec *responseRecorder) {
+	atomic.AddInt64(&m.inFlight, -1)
+	atomic.AddInt64(&m.total, 1)
+	atomic.AddInt64(&m.durationNanos, int64(time.Since(start)))
+	if rec.status() >= http.StatusInternalServerError || rec.Header().Get("X-Faas-Response-Error-Code") != "" {
+		atomic.AddInt64(&m.errors, 1)
+	}
+}
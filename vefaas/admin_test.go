@@ -0,0 +1,87 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+func TestAdminHandler(t *testing.T) {
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		return &events.EventResponse{}, nil
+	}
+	initializer := func(ctx context.Context) error { return nil }
+	server := newFunctionServer(handler, newOptions(WithInitializer(initializer)))
+	admin := server.adminHandler()
+
+	get := func(method, path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		admin.ServeHTTP(rw, httptest.NewRequest(method, path, nil))
+		return rw
+	}
+
+	if rw := get(http.MethodGet, "/healthz"); rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("healthz before initialization = %d, want %d", rw.Code, http.StatusServiceUnavailable)
+	}
+	// No X-Faas-Internal-Request needed on the admin listener.
+	if rw := get(http.MethodPost, "/v1/initialize"); rw.Code != http.StatusOK {
+		t.Fatalf("initialize = %d, want %d", rw.Code, http.StatusOK)
+	}
+	if rw := get(http.MethodGet, "/healthz"); rw.Code != http.StatusOK {
+		t.Errorf("healthz after initialization = %d, want %d", rw.Code, http.StatusOK)
+	}
+
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	metrics := get(http.MethodGet, "/metrics").Body.String()
+	for _, want := range []string{"vefaas_invocations_total 1\n", "vefaas_invocations_in_flight 0\n", "vefaas_initialized 1\n"} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics missing %q:\n%s", want, metrics)
+		}
+	}
+
+	if rw := get(http.MethodGet, "/debug/pprof/"); rw.Code != http.StatusOK {
+		t.Errorf("pprof = %d, want %d", rw.Code, http.StatusOK)
+	}
+
+	// Diagnostics are never served on the function port.
+	var invoked bool
+	handler = func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		invoked = true
+		return &events.EventResponse{}, nil
+	}
+	newFunctionServer(handler, newOptions()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !invoked {
+		t.Error("/metrics on the function port is not handled by the function")
+	}
+
+	// Nor are the internal requests with the admin listener set up.
+	invoked = false
+	server = newFunctionServer(handler, newOptions())
+	server.internalOnAdmin = true
+	rq := httptest.NewRequest(http.MethodGet, "/v1/version", nil)
+	rq.RemoteAddr = "127.0.0.1:1234"
+	rq.Header.Set(internalRequestHeader, "true")
+	server.ServeHTTP(httptest.NewRecorder(), rq)
+	if !invoked {
+		t.Error("internal request on the function port is not handled by the function")
+	}
+}
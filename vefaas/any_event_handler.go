@@ -21,23 +21,16 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/cloudevents/sdk-go/v2/binding"
-	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"github.com/volcengine/vefaas-golang-runtime/events"
 	"github.com/volcengine/vefaas-golang-runtime/utils"
-	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
 )
 
-func handleAnyEvent(handler interface{}) func(rw http.ResponseWriter, rq *http.Request) {
+func handleAnyEvent(handler interface{}, o *options) func(rw http.ResponseWriter, rq *http.Request) {
 	functionHandler := handler.(anyFunctionHandler)
 	return func(rw http.ResponseWriter, rq *http.Request) {
-		defer utils.RecoverFunc(rw, nil)
+		defer recoverFunction(rq.Context(), rw)
 
-		ctx := rq.Context()
-		ctx = vefaascontext.WithRequestIdContext(ctx, rq)
-		ctx = vefaascontext.WithAccessKeyIdContext(ctx, rq)
-		ctx = vefaascontext.WithSecretAccessKeyContext(ctx, rq)
-		ctx = vefaascontext.WithSessionTokenContext(ctx, rq)
+		ctx := withInvocationContext(rq.Context(), rq)
 
 		remoteAddr := rq.RemoteAddr
 		remoteIP := rq.Header.Get("X-Real-Ip")
@@ -67,15 +60,28 @@ func handleAnyEvent(handler interface{}) func(rw http.ResponseWriter, rq *http.R
 				Body:                  rawBody,
 			}
 			utils.SetHttpParamsAndHeaders(req, rq)
+			if !o.keepPlatformHeaders {
+				utils.StripPlatformHeaders(req.Headers)
+			}
 			payload = req
 		case events.EventTypeCloudEvent:
-			msg := cehttp.NewMessageFromHttpRequest(rq)
-			event, err := binding.ToEvent(ctx, msg)
+			if isCloudEventBatch(rq) {
+				batch, err := readCloudEventBatch(rq)
+				if err != nil {
+					utils.SetInvalidCloudEventHeader(rw, err)
+					return
+				}
+				annotateCloudEventBatchSpan(ctx, batch)
+				payload = batch
+				break
+			}
+			ce, err := readCloudEvent(ctx, rq)
 			if err != nil {
 				utils.SetInvalidCloudEventHeader(rw, err)
 				return
 			}
-			payload = &events.CloudEvent{Event: event}
+			annotateCloudEventSpan(ctx, ce)
+			payload = ce
 		default:
 			utils.SetInvalidEventTypeHeader(rw, eventType, events.EventTypeHTTP, events.EventTypeCloudEvent)
 			return
@@ -83,30 +89,6 @@ func handleAnyEvent(handler interface{}) func(rw http.ResponseWriter, rq *http.R
 
 		startTime := time.Now()
 		resp, err := functionHandler(ctx, payload)
-		utils.SetExecutionDurationHeader(rw, startTime)
-
-		if err != nil {
-			utils.SetFunctionExecutionErrorHeader(rw, err)
-			return
-		}
-		if resp == nil {
-			utils.SetFunctionNoResponseErrorHeader(rw)
-			return
-		}
-
-		if resp.Headers != nil {
-			for k, v := range resp.Headers {
-				rw.Header().Set(k, v)
-			}
-		}
-		// In case user set this response header, we need to rewrite it here.
-		utils.SetExecutionDurationHeader(rw, startTime)
-
-		if resp.StatusCode != 0 {
-			rw.WriteHeader(resp.StatusCode)
-		}
-		if resp.Body != nil {
-			_, _ = rw.Write(resp.Body)
-		}
+		writeEventResponse(ctx, rw, startTime, resp, err)
 	}
 }
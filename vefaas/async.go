@@ -0,0 +1,160 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/utils"
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+)
+
+const (
+	invocationTypeHeader = "X-Faas-Invocation-Type"
+	invocationTypeAsync  = "async"
+
+	asyncEventSource     = "vefaas/async"
+	asyncDeliveryTimeout = 30 * time.Second
+)
+
+// asyncClient delivers the results of the asynchronous invocations, retrying
+// the failed deliveries as they carry an idempotency key.
+var asyncClient = &http.Client{Transport: NewTransport(nil)}
+
+// isAsyncInvocation reports whether r asks for an asynchronous invocation.
+func isAsyncInvocation(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get(invocationTypeHeader), invocationTypeAsync)
+}
+
+// serveAsync accepts the asynchronous invocation r, which is handled in the
+// background once the request body has been read. The invocations are refused
+// once the server is shutting down, as they would not be waited for.
+func (s *functionServer) serveAsync(rw http.ResponseWriter, r *http.Request, debug bool) {
+	rw = &responseRecorder{ResponseWriter: rw, renderer: s.renderer, debug: debug}
+	if atomic.LoadInt32(&s.shuttingDown) != 0 {
+		e := NewError(http.StatusServiceUnavailable, "server_shutting_down", "The server is shutting down.")
+		e.Retryable = true
+		writeError(rw, e)
+		return
+	}
+	body, err := utils.RawBodyFromHttpRequest(r)
+	if err != nil {
+		writeError(rw, BadRequest("Failed to read request body, %v.", err))
+		return
+	}
+
+	// The invocation is detached from the request, which ends right away.
+	rq := r.Clone(context.Background())
+	rq.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	s.asyncPending.Add(1)
+	go func() {
+		defer s.asyncPending.Done()
+		s.invokeAsync(rq, debug)
+	}()
+
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+// invokeAsync handles the asynchronous invocation rq and delivers its result.
+func (s *functionServer) invokeAsync(rq *http.Request, debug bool) {
+	w := newBufferedResponseWriter()
+	s.invoke(w, rq, debug)
+
+	result := &events.AsyncInvocationResult{
+		RequestId:    rq.Header.Get("X-Faas-Request-Id"),
+		StatusCode:   w.statusCode,
+		Headers:      make(map[string]string, len(w.header)),
+		Body:         w.body.Bytes(),
+		ErrorCode:    w.header.Get("X-Faas-Response-Error-Code"),
+		ErrorMessage: w.header.Get("X-Faas-Response-Error-Message"),
+	}
+	if result.StatusCode == 0 {
+		result.StatusCode = http.StatusOK
+	}
+	for k := range w.header {
+		result.Headers[k] = w.header.Get(k)
+	}
+
+	destination, eventType := s.asyncOnSuccess, events.FaasAsyncSuccessEvent
+	if result.Failed() {
+		destination, eventType = s.asyncOnFailure, events.FaasAsyncFailureEvent
+		fmt.Fprintf(os.Stderr, "Async invocation %s failed with status %d, %s.\n",
+			result.RequestId, result.StatusCode, result.ErrorMessage)
+	}
+	if destination == "" {
+		return
+	}
+	if err := deliverAsyncResult(rq, destination, eventType, result); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to deliver result of async invocation %s, %v.\n", result.RequestId, err)
+	}
+}
+
+// deliverAsyncResult sends result of the invocation rq to destination as a
+// binary mode CloudEvent of eventType.
+func deliverAsyncResult(rq *http.Request, destination, eventType string, result *events.AsyncInvocationResult) error {
+	event := events.NewCloudEvent(eventType, asyncEventSource, result)
+	event.SetSubject(result.RequestId)
+
+	ctx, cancel := context.WithTimeout(context.Background(), asyncDeliveryTimeout)
+	defer cancel()
+	ctx = vefaascontext.WithRequestIdContext(ctx, rq)
+
+	delivery, err := events.NewBinaryRequest(destination, event)
+	if err != nil {
+		return err
+	}
+	delivery = delivery.WithContext(ctx)
+	delivery.Header.Set("Idempotency-Key", event.ID())
+
+	resp, err := asyncClient.Do(delivery)
+	if err != nil {
+		return err
+	}
+	_, _ = ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("destination responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// waitAsync waits for the pending asynchronous invocations to finish, until ctx
+// is done.
+func (s *functionServer) waitAsync(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.asyncPending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
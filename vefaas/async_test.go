@@ -0,0 +1,188 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+// destination collects the CloudEvents delivered to it.
+type destination struct {
+	events chan *events.CloudEvent
+}
+
+func (d *destination) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	event, err := binding.ToEvent(r.Context(), cehttp.NewMessageFromHttpRequest(r))
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	d.events <- &events.CloudEvent{Event: event}
+}
+
+func TestAsyncInvocation(t *testing.T) {
+	release := make(chan struct{})
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		<-release
+		if string(r.Body) == "fail" {
+			return nil, errors.New("boom")
+		}
+		return &events.EventResponse{Body: append([]byte("echo "), r.Body...)}, nil
+	}
+	onSuccess := &destination{events: make(chan *events.CloudEvent, 1)}
+	onFailure := &destination{events: make(chan *events.CloudEvent, 1)}
+	successServer := httptest.NewServer(onSuccess)
+	defer successServer.Close()
+	failureServer := httptest.NewServer(onFailure)
+	defer failureServer.Close()
+
+	s := newFunctionServer(handler, newOptions(WithAsyncInvocation(true), WithAsyncDestinations(successServer.URL, failureServer.URL)))
+
+	cases := []struct {
+		body      string
+		dest      *destination
+		eventType string
+		status    int
+	}{
+		{"hello", onSuccess, events.FaasAsyncSuccessEvent, http.StatusOK},
+		{"fail", onFailure, events.FaasAsyncFailureEvent, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		rq := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+		rq.Header.Set("X-Faas-Request-Id", "req-"+c.body)
+		rq.Header.Set("X-Faas-Invocation-Type", "async")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, rq)
+		if rec.Code != http.StatusAccepted || rec.Header().Get("X-Faas-Request-Id") != "req-"+c.body {
+			t.Fatalf("response = %d %v, want 202", rec.Code, rec.Header())
+		}
+		release <- struct{}{}
+
+		var event *events.CloudEvent
+		select {
+		case event = <-c.dest.events:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("result of %q not delivered", c.body)
+		}
+		var result events.AsyncInvocationResult
+		if err := event.DataAs(&result); err != nil {
+			t.Fatalf("DataAs() error = %v", err)
+		}
+		if event.Type() != c.eventType || event.Subject() != "req-"+c.body ||
+			result.RequestId != "req-"+c.body || result.StatusCode != c.status {
+			t.Errorf("event = %v, result = %+v", event, result)
+		}
+		if c.status == http.StatusOK && string(result.Body) != "echo hello" {
+			t.Errorf("result body = %q", result.Body)
+		}
+		if c.status != http.StatusOK && result.ErrorCode != "function_execution_error" {
+			t.Errorf("result error code = %q", result.ErrorCode)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.waitAsync(ctx); err != nil {
+		t.Errorf("waitAsync() error = %v", err)
+	}
+}
+
+func TestAsyncInvocationWait(t *testing.T) {
+	release := make(chan struct{})
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		<-release
+		return &events.EventResponse{}, nil
+	}
+	s := newFunctionServer(handler, newOptions(WithAsyncInvocation(true)))
+
+	rq := httptest.NewRequest(http.MethodPost, "/", nil)
+	rq.Header.Set("X-Faas-Invocation-Type", "async")
+	s.ServeHTTP(httptest.NewRecorder(), rq)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.waitAsync(ctx); err != context.DeadlineExceeded {
+		t.Errorf("waitAsync() error = %v, want deadline exceeded", err)
+	}
+
+	close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.waitAsync(ctx); err != nil {
+		t.Errorf("waitAsync() error = %v", err)
+	}
+}
+
+func TestAsyncInvocationDisabled(t *testing.T) {
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		return &events.EventResponse{Body: []byte("sync")}, nil
+	}
+	s := newFunctionServer(handler, newOptions(WithAsyncInvocation(false)))
+
+	rq := httptest.NewRequest(http.MethodPost, "/", nil)
+	rq.Header.Set("X-Faas-Invocation-Type", "async")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, rq)
+	if rec.Code != http.StatusOK || rec.Body.String() != "sync" {
+		t.Errorf("response = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAsyncInvocationRefused(t *testing.T) {
+	var invoked bool
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		invoked = true
+		return &events.EventResponse{}, nil
+	}
+	s := newFunctionServer(handler, newOptions(WithAsyncInvocation(true)))
+
+	// The request body can not be read.
+	rq := httptest.NewRequest(http.MethodPost, "/", iotest.ErrReader(errors.New("connection reset")))
+	rq.Header.Set("X-Faas-Invocation-Type", "async")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, rq)
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("X-Faas-Response-Error-Code") != "bad_request" {
+		t.Errorf("response = %d %v, want 400", rec.Code, rec.Header())
+	}
+
+	// The server is shutting down.
+	atomic.StoreInt32(&s.shuttingDown, 1)
+	rq = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	rq.Header.Set("X-Faas-Invocation-Type", "async")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, rq)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("X-Faas-Response-Error-Code") != "server_shutting_down" {
+		t.Errorf("response = %d %v, want 503", rec.Code, rec.Header())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.waitAsync(ctx); err != nil || invoked {
+		t.Errorf("waitAsync() error = %v, invoked = %v", err, invoked)
+	}
+}
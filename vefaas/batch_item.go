@@ -0,0 +1,251 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+)
+
+// BatchItemOption configures the handlers created by KafkaMessageHandler and
+// RocketMqMessageHandler.
+type BatchItemOption func(*batchItemOptions)
+
+type batchItemOptions struct {
+	concurrency int
+}
+
+// WithItemConcurrency sets how many partitions, or queues of rocketmq, are
+// processed concurrently. The messages of a partition are always processed in
+// order. The default is 1, which processes all the messages in order.
+func WithItemConcurrency(n int) BatchItemOption {
+	return func(o *batchItemOptions) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+// KafkaMessageHandler creates a CloudEvent handler of kafka and bmq trigger
+// events, which processes their messages one by one with handle:
+//
+//	vefaas.Start(vefaas.KafkaMessageHandler(func(ctx context.Context, m *events.KafkaMessage) error {
+//		return save(ctx, m.Value)
+//	}))
+//
+// A failed message doesn't fail the others. Once a message of a partition
+// failed, the following messages of the partition are skipped and reported as
+// failed too, so they are redelivered in order, unless the error is Terminal.
+// The handler responds with an events.BatchItemFailureReport listing the failed
+// messages, with status 207 if any, so that only those are redelivered.
+// Retryable and Terminal apply to each message, the skipped messages are
+// retried after the RetryAfter of the failed one.
+func KafkaMessageHandler(handle func(context.Context, *events.KafkaMessage) error, opts ...BatchItemOption) func(context.Context, *events.CloudEvent) (*events.EventResponse, error) {
+	o := newBatchItemOptions(opts)
+	return func(ctx context.Context, event *events.CloudEvent) (*events.EventResponse, error) {
+		if t := event.Type(); t != events.FaasKafkaEvent && t != events.FaasBmqEvent {
+			return nil, NewError(http.StatusBadRequest, "invalid_event_type", "event type %q carries no kafka messages", t)
+		}
+		var data events.KafkaEventData
+		if err := event.DataAs(&data); err != nil {
+			return nil, BadRequest("failed to decode kafka messages, %v", err)
+		}
+
+		items := make([]batchItem, 0, len(data.Messages))
+		for i := range data.Messages {
+			m := &data.Messages[i]
+			items = append(items, batchItem{
+				partition: m.Topic + "/" + strconv.Itoa(int(m.Partition)),
+				failure:   events.BatchItemFailure{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset},
+				handle:    func(ctx context.Context) error { return handle(ctx, m) },
+			})
+		}
+		return processBatchItems(ctx, items, o.concurrency)
+	}
+}
+
+// RocketMqMessageHandler creates a CloudEvent handler of rocketmq trigger events,
+// which processes their messages one by one with handle, see KafkaMessageHandler.
+// The messages of a queue are processed in order.
+func RocketMqMessageHandler(handle func(context.Context, *events.RocketMqMessage) error, opts ...BatchItemOption) func(context.Context, *events.CloudEvent) (*events.EventResponse, error) {
+	o := newBatchItemOptions(opts)
+	return func(ctx context.Context, event *events.CloudEvent) (*events.EventResponse, error) {
+		if t := event.Type(); t != events.FaasRocketMqEvent {
+			return nil, NewError(http.StatusBadRequest, "invalid_event_type", "event type %q carries no rocketmq messages", t)
+		}
+		var data events.RocketMqEventData
+		if err := event.DataAs(&data); err != nil {
+			return nil, BadRequest("failed to decode rocketmq messages, %v", err)
+		}
+
+		items := make([]batchItem, 0, len(data.Messages))
+		for i := range data.Messages {
+			m := &data.Messages[i]
+			items = append(items, batchItem{
+				partition: m.Topic + "/" + strconv.Itoa(int(m.QueueId)),
+				failure: events.BatchItemFailure{
+					Topic:     m.Topic,
+					Partition: m.QueueId,
+					Offset:    m.QueueOffset,
+					MessageId: m.MsgId,
+				},
+				handle: func(ctx context.Context) error { return handle(ctx, m) },
+			})
+		}
+		return processBatchItems(ctx, items, o.concurrency)
+	}
+}
+
+func newBatchItemOptions(opts []BatchItemOption) *batchItemOptions {
+	o := &batchItemOptions{concurrency: 1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// batchItem is a message of a message queue trigger event.
+type batchItem struct {
+	// partition is the ordering key of the message.
+	partition string
+	// failure is the failure report of the message, with the location filled.
+	failure events.BatchItemFailure
+	handle  func(ctx context.Context) error
+}
+
+// processBatchItems processes the partitions of items, at most concurrency at a
+// time, and responds with the report of the failed items.
+func processBatchItems(ctx context.Context, items []batchItem, concurrency int) (*events.EventResponse, error) {
+	// The indexes of items by partition, in order.
+	var partitions [][]int
+	index := make(map[string]int)
+	for i, item := range items {
+		n, ok := index[item.partition]
+		if !ok {
+			n = len(partitions)
+			index[item.partition] = n
+			partitions = append(partitions, nil)
+		}
+		partitions[n] = append(partitions[n], i)
+	}
+
+	failed := make([]bool, len(items))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, partition := range partitions {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(partition []int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			for n, i := range partition {
+				err := processBatchItem(ctx, &items[i])
+				if err == nil {
+					continue
+				}
+				fillBatchItemFailure(&items[i].failure, err)
+				failed[i] = true
+				if items[i].failure.Terminal {
+					// The message is not redelivered, thus not in the way.
+					continue
+				}
+
+				// Keep the order of the partition on redelivery.
+				for _, j := range partition[n+1:] {
+					items[j].failure.ErrorCode = "batch_item_skipped"
+					items[j].failure.ErrorMessage = fmt.Sprintf("Skipped after the failure of offset %d.", items[i].failure.Offset)
+					items[j].failure.Retryable = true
+					items[j].failure.RetryAfter = items[i].failure.RetryAfter
+					failed[j] = true
+				}
+				return
+			}
+		}(partition)
+	}
+	wg.Wait()
+
+	report := &events.BatchItemFailureReport{BatchItemFailures: []events.BatchItemFailure{}}
+	for i := range items {
+		if failed[i] {
+			report.BatchItemFailures = append(report.BatchItemFailures, items[i].failure)
+		}
+	}
+	tracing.SpanFromContext(ctx).SetAttribute("messaging.batch.message_count", len(items))
+	tracing.SpanFromContext(ctx).SetAttribute("vefaas.batch.failed_count", len(report.BatchItemFailures))
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	statusCode := http.StatusOK
+	if len(report.BatchItemFailures) > 0 {
+		statusCode = http.StatusMultiStatus
+		markBatchItemFailures(ctx)
+	}
+	return &events.EventResponse{
+		StatusCode: statusCode,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       body,
+	}, nil
+}
+
+// processBatchItem processes item, turning a panic into an error.
+func processBatchItem(ctx context.Context, item *batchItem) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			stack := debug.Stack()
+			_, _ = fmt.Fprintf(os.Stderr, "panic: %v\n%s", v, stack)
+			tracing.SpanFromContext(ctx).RecordPanic(v, stack)
+			err = NewError(http.StatusInternalServerError, "function_panic", "Function panic, please check log for more details.")
+		}
+	}()
+
+	return item.handle(ctx)
+}
+
+// fillBatchItemFailure describes err in f.
+func fillBatchItemFailure(f *events.BatchItemFailure, err error) {
+	var fe *Error
+	if !errors.As(err, &fe) {
+		f.ErrorCode = "function_execution_error"
+		f.ErrorMessage = fmt.Sprintf("Function returns error, %v.", err)
+		return
+	}
+
+	f.ErrorCode = fe.Code
+	if f.ErrorCode == "" {
+		f.ErrorCode = "function_execution_error"
+	}
+	f.ErrorMessage = fe.Message
+	f.Retryable = fe.Retryable
+	f.Terminal = fe.Terminal
+	if fe.Retryable && fe.RetryAfter > 0 {
+		f.RetryAfter = retryAfterSeconds(fe.RetryAfter)
+	}
+}
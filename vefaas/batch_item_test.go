@@ -0,0 +1,169 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+func invokeBatchItems(t *testing.T, handler interface{}, event *events.CloudEvent) (int, *events.BatchItemFailureReport) {
+	rq, err := events.NewBinaryRequest("http://localhost/", event)
+	if err != nil {
+		t.Fatalf("NewBinaryRequest() error = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	newFunctionServer(handler, newOptions()).ServeHTTP(rec, rq)
+
+	var report events.BatchItemFailureReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("response = %d %s", rec.Code, rec.Body)
+	}
+	return rec.Code, &report
+}
+
+func TestKafkaMessageHandler(t *testing.T) {
+	var mu sync.Mutex
+	var processed []string
+	handler := KafkaMessageHandler(func(ctx context.Context, m *events.KafkaMessage) error {
+		mu.Lock()
+		processed = append(processed, string(m.Value))
+		mu.Unlock()
+		switch string(m.Value) {
+		case "fail":
+			return Retryable(errors.New("timeout")).WithRetryAfter(30 * time.Second)
+		case "poison":
+			return Terminal(errors.New("malformed"))
+		case "panic":
+			panic("oops")
+		}
+		return nil
+	})
+
+	code, report := invokeBatchItems(t, handler, events.NewKafkaEvent("orders", []byte("ok")))
+	if code != http.StatusOK || len(report.BatchItemFailures) != 0 {
+		t.Errorf("all succeeded: %d %+v", code, report)
+	}
+
+	// All the messages are at partition 0, so the ones after a non-terminal
+	// failure are skipped.
+	processed = nil
+	event := events.NewKafkaEvent("orders", []byte("ok"), []byte("poison"), []byte("ok"), []byte("fail"), []byte("skipped"))
+	code, report = invokeBatchItems(t, handler, event)
+	if code != http.StatusMultiStatus {
+		t.Errorf("status = %d, want %d", code, http.StatusMultiStatus)
+	}
+	want := []struct {
+		offset int64
+		code   string
+	}{
+		{1, "function_terminal_error"},
+		{3, "function_retryable_error"},
+		{4, "batch_item_skipped"},
+	}
+	if len(report.BatchItemFailures) != len(want) {
+		t.Fatalf("failures = %+v", report.BatchItemFailures)
+	}
+	for i, w := range want {
+		f := report.BatchItemFailures[i]
+		if f.Topic != "orders" || f.Offset != w.offset || f.ErrorCode != w.code {
+			t.Errorf("failure %d = %+v, want offset %d code %s", i, f, w.offset, w.code)
+		}
+	}
+	if !report.BatchItemFailures[0].Terminal || !report.BatchItemFailures[1].Retryable || !report.BatchItemFailures[2].Retryable {
+		t.Errorf("retry flags = %+v", report.BatchItemFailures)
+	}
+	if report.BatchItemFailures[1].RetryAfter != 30 || report.BatchItemFailures[2].RetryAfter != 30 {
+		t.Errorf("retry after = %+v", report.BatchItemFailures)
+	}
+	if len(processed) != 4 {
+		t.Errorf("processed = %q, want the skipped message unprocessed", processed)
+	}
+
+	code, report = invokeBatchItems(t, handler, events.NewBmqEvent("orders", []byte("panic")))
+	if code != http.StatusMultiStatus || len(report.BatchItemFailures) != 1 || report.BatchItemFailures[0].ErrorCode != "function_panic" {
+		t.Errorf("panic: %d %+v", code, report)
+	}
+}
+
+func TestKafkaMessageHandlerPartitions(t *testing.T) {
+	event := events.NewKafkaEvent("orders", []byte("a0"), []byte("b0"), []byte("a1"), []byte("b1"), []byte("a2"))
+	var data events.KafkaEventData
+	_ = event.DataAs(&data)
+	for i := range data.Messages {
+		data.Messages[i].Partition = int32(data.Messages[i].Value[0] - 'a')
+	}
+	_ = event.SetData("application/json", data)
+
+	var mu sync.Mutex
+	order := make(map[int32][]string)
+	handler := KafkaMessageHandler(func(ctx context.Context, m *events.KafkaMessage) error {
+		mu.Lock()
+		order[m.Partition] = append(order[m.Partition], string(m.Value))
+		mu.Unlock()
+		if string(m.Value) == "a1" {
+			return errors.New("boom")
+		}
+		return nil
+	}, WithItemConcurrency(2))
+
+	code, report := invokeBatchItems(t, handler, event)
+	if code != http.StatusMultiStatus || len(report.BatchItemFailures) != 2 {
+		t.Fatalf("response = %d %+v", code, report)
+	}
+	if f := report.BatchItemFailures; f[0].Offset != 2 || f[0].ErrorCode != "function_execution_error" ||
+		f[1].Offset != 4 || f[1].ErrorCode != "batch_item_skipped" {
+		t.Errorf("failures = %+v", f)
+	}
+	if got := order[0]; len(got) != 2 || got[0] != "a0" || got[1] != "a1" {
+		t.Errorf("partition 0 processed %q", got)
+	}
+	if got := order[1]; len(got) != 2 || got[0] != "b0" || got[1] != "b1" {
+		t.Errorf("partition 1 processed %q", got)
+	}
+}
+
+func TestRocketMqMessageHandler(t *testing.T) {
+	handler := RocketMqMessageHandler(func(ctx context.Context, m *events.RocketMqMessage) error {
+		if string(m.Body) == "bad" {
+			return BadRequest("bad message")
+		}
+		return nil
+	})
+
+	code, report := invokeBatchItems(t, handler, events.NewRocketMqEvent("orders", []byte("ok"), []byte("bad")))
+	if code != http.StatusMultiStatus || len(report.BatchItemFailures) != 1 {
+		t.Fatalf("response = %d %+v", code, report)
+	}
+	if f := report.BatchItemFailures[0]; f.MessageId == "" || f.Offset != 1 || f.ErrorCode != "bad_request" {
+		t.Errorf("failure = %+v", f)
+	}
+
+	rq, _ := events.NewBinaryRequest("http://localhost/", events.NewTimerEvent("timer", ""))
+	rec := httptest.NewRecorder()
+	newFunctionServer(handler, newOptions()).ServeHTTP(rec, rq)
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("X-Faas-Response-Error-Code") != "invalid_event_type" {
+		t.Errorf("timer event response = %d %s", rec.Code, rec.Body)
+	}
+}
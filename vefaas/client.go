@@ -0,0 +1,255 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+)
+
+const (
+	// DefaultMaxRetries is the max retries of the transports created by
+	// NewTransport and HTTPClient.
+	DefaultMaxRetries = 2
+
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultMaxRetryBackoff = 2 * time.Second
+
+	// DefaultDeadlineReserve is the time reserved for the function to respond
+	// after the downstream calls, see Transport.DeadlineReserve.
+	DefaultDeadlineReserve = 500 * time.Millisecond
+)
+
+// Transport is an http.RoundTripper for calling downstream services from
+// function handler. It
+//   - propagates X-Faas-Request-Id, the W3C trace context of a client span and
+//     the W3C baggage of the invocation,
+//   - bounds every call by the remaining time of the invocation, less
+//     DeadlineReserve,
+//   - retries idempotent requests with exponential backoff on network errors and
+//     429, 502, 503, 504 responses.
+//
+// The invocation is taken from the request context, so use it with requests
+// created by http.NewRequestWithContext with the handler context. A Transport
+// is safe for concurrent use, and can be created once in initializer.
+type Transport struct {
+	// Base is the underlying RoundTripper, http.DefaultTransport if nil.
+	Base http.RoundTripper
+
+	// MaxRetries is the max retries of a failed idempotent request, zero
+	// disables retry.
+	MaxRetries int
+	// RetryBackoff is the initial backoff between retries, doubled on every
+	// retry up to MaxRetryBackoff, with full jitter. A Retry-After in seconds
+	// from the server takes precedence, but is also capped at MaxRetryBackoff.
+	// No retry is made if the backoff would pass the deadline of the request.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+
+	// DeadlineReserve is subtracted from the invocation deadline for each call.
+	DeadlineReserve time.Duration
+
+	// ctx is the fallback invocation context of requests without one.
+	ctx context.Context
+}
+
+// NewTransport creates a Transport with the default retry and deadline settings
+// on top of base.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{
+		Base:            base,
+		MaxRetries:      DefaultMaxRetries,
+		RetryBackoff:    defaultRetryBackoff,
+		MaxRetryBackoff: defaultMaxRetryBackoff,
+		DeadlineReserve: DefaultDeadlineReserve,
+	}
+}
+
+// HTTPClient returns an http.Client using Transport for the invocation of ctx,
+// which also applies to the requests created without the handler context.
+//
+//	client := vefaas.HTTPClient(ctx)
+//	resp, err := client.Get("http://inventory.internal/items/42")
+func HTTPClient(ctx context.Context) *http.Client {
+	t := NewTransport(nil)
+	t.ctx = ctx
+
+	return &http.Client{Transport: t}
+}
+
+// RoundTrip sends rq with the invocation metadata, retrying if allowed. The
+// original request is not modified.
+func (t *Transport) RoundTrip(rq *http.Request) (*http.Response, error) {
+	invocation := rq.Context()
+	if vefaascontext.RequestIdFromContext(invocation) == "" && t.ctx != nil {
+		invocation = t.ctx
+	}
+
+	ctx, cancel := rq.Context(), context.CancelFunc(func() {})
+	if deadline, ok := invocation.Deadline(); ok {
+		deadline = deadline.Add(-t.DeadlineReserve)
+		if !time.Now().Before(deadline) {
+			closeRequestBody(rq)
+			return nil, context.DeadlineExceeded
+		}
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+	}
+
+	_, span := tracing.StartSpan(invocation, "HTTP "+rq.Method, tracing.SpanKindClient)
+	span.SetAttribute("http.request.method", rq.Method)
+	span.SetAttribute("url.full", rq.URL.Redacted())
+
+	resp, err := t.roundTrip(ctx, invocation, span, rq)
+	if err != nil {
+		span.RecordError(err)
+		span.End()
+		cancel()
+		return nil, err
+	}
+	span.SetAttribute("http.response.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(tracing.StatusError, resp.Status)
+	}
+	span.End()
+	// The deadline must outlive RoundTrip until the body is consumed.
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+func (t *Transport) roundTrip(ctx, invocation context.Context, span *tracing.Span, rq *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	retryable := isIdempotent(rq) && (rq.Body == nil || rq.Body == http.NoBody || rq.GetBody != nil)
+
+	for attempt := 0; ; attempt++ {
+		out := rq.Clone(ctx)
+		if attempt > 0 && rq.GetBody != nil {
+			body, err := rq.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			out.Body = body
+		}
+		t.injectHeaders(invocation, span, out.Header)
+
+		resp, err := base.RoundTrip(out)
+		if attempt >= t.MaxRetries || !retryable || !shouldRetry(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt, resp)
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4<<10))
+			_ = resp.Body.Close()
+		}
+		span.AddEvent("retry", map[string]interface{}{"http.request.resend_count": attempt + 1})
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (t *Transport) injectHeaders(invocation context.Context, span *tracing.Span, h http.Header) {
+	if id := vefaascontext.RequestIdFromContext(invocation); id != "" && h.Get("X-Faas-Request-Id") == "" {
+		h.Set("X-Faas-Request-Id", id)
+	}
+	if h.Get(tracing.TraceparentHeader) == "" {
+		tracing.InjectHeader(span.SpanContext(), h)
+	}
+	if baggage := tracing.BaggageFromContext(invocation); baggage != "" && h.Get(tracing.BaggageHeader) == "" {
+		h.Set(tracing.BaggageHeader, baggage)
+	}
+}
+
+func (t *Transport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			wait := time.Duration(seconds) * time.Second
+			if t.MaxRetryBackoff > 0 && (wait > t.MaxRetryBackoff || wait < 0) {
+				wait = t.MaxRetryBackoff
+			}
+			return wait
+		}
+	}
+	if t.RetryBackoff <= 0 {
+		return 0
+	}
+	max := t.RetryBackoff << uint(attempt)
+	if t.MaxRetryBackoff > 0 && (max > t.MaxRetryBackoff || max <= 0) {
+		max = t.MaxRetryBackoff
+	}
+
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
+
+// isIdempotent follows RFC 7231, requests carrying an idempotency key are also
+// considered idempotent.
+func isIdempotent(rq *http.Request) bool {
+	switch rq.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return rq.Header.Get("Idempotency-Key") != "" || rq.Header.Get("X-Idempotency-Key") != ""
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func closeRequestBody(rq *http.Request) {
+	if rq.Body != nil {
+		_ = rq.Body.Close()
+	}
+}
+
+// cancelBody releases the deadline of a call once its response body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
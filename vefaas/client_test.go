@@ -0,0 +1,181 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+)
+
+func TestHTTPClientPropagation(t *testing.T) {
+	var calls int32
+	var got http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		got = r.Header.Clone()
+	}))
+	defer downstream.Close()
+
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("invocation context has no deadline")
+		}
+		resp, err := HTTPClient(ctx).Get(downstream.URL)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		return &events.EventResponse{StatusCode: resp.StatusCode}, nil
+	}
+	server := newFunctionServer(handler, newOptions())
+
+	rq := httptest.NewRequest(http.MethodGet, "/", nil)
+	rq.Header.Set("X-Faas-Request-Id", "req-1")
+	rq.Header.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	rq.Header.Set("Baggage", "tenant=acme")
+	rw := httptest.NewRecorder()
+	server.ServeHTTP(rw, rq)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", rw.Code, http.StatusOK, rw.Body.String())
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+	if id := got.Get("X-Faas-Request-Id"); id != "req-1" {
+		t.Errorf("X-Faas-Request-Id = %q, want %q", id, "req-1")
+	}
+	sc, ok := tracing.SpanContextFromHeader(got)
+	if !ok || sc.TraceID.String() != "0af7651916cd43dd8448eb211c80319c" || sc.SpanID.String() == "b7ad6b7169203331" {
+		t.Errorf("traceparent = %q, want a child span of the invocation", got.Get("Traceparent"))
+	}
+	if baggage := got.Get("Baggage"); baggage != "tenant=acme" {
+		t.Errorf("Baggage = %q, want %q", baggage, "tenant=acme")
+	}
+}
+
+func TestTransportRetry(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		header string
+		calls  int32
+	}{
+		{"idempotent", http.MethodPut, "", 3},
+		{"not idempotent", http.MethodPost, "", 1},
+		{"idempotency key", http.MethodPost, "Idempotency-Key", 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var calls int32
+			downstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				rw.WriteHeader(http.StatusBadGateway)
+			}))
+			defer downstream.Close()
+
+			transport := NewTransport(nil)
+			transport.RetryBackoff = time.Millisecond
+			rq, _ := http.NewRequest(c.method, downstream.URL, strings.NewReader("payload"))
+			if c.header != "" {
+				rq.Header.Set(c.header, "key-1")
+			}
+			resp, err := (&http.Client{Transport: transport}).Do(rq)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != http.StatusBadGateway || calls != c.calls {
+				t.Errorf("status = %d, calls = %d, want %d, %d", resp.StatusCode, calls, http.StatusBadGateway, c.calls)
+			}
+		})
+	}
+}
+
+func TestTransportRetryAfter(t *testing.T) {
+	var calls int32
+	downstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		rw.Header().Set("Retry-After", "3600")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer downstream.Close()
+
+	// Retry-After is capped at MaxRetryBackoff.
+	transport := NewTransport(nil)
+	transport.MaxRetries = 1
+	transport.MaxRetryBackoff = 10 * time.Millisecond
+	start := time.Now()
+	resp, err := (&http.Client{Transport: transport}).Get(downstream.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	_ = resp.Body.Close()
+	if calls != 2 || time.Since(start) > time.Second {
+		t.Errorf("calls = %d in %v, want 2 with capped backoff", calls, time.Since(start))
+	}
+
+	// No retry if the backoff passes the deadline of the request.
+	atomic.StoreInt32(&calls, 0)
+	transport.MaxRetryBackoff = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	rq, _ := http.NewRequestWithContext(ctx, http.MethodGet, downstream.URL, nil)
+	resp, err = (&http.Client{Transport: transport}).Do(rq)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	_ = resp.Body.Close()
+	if calls != 1 || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, calls = %d, want %d, 1", resp.StatusCode, calls, http.StatusServiceUnavailable)
+	}
+}
+
+func TestTransportDeadline(t *testing.T) {
+	downstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer downstream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := HTTPClient(ctx).Get(downstream.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	// The call is bounded by the invocation deadline less the reserve.
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("call took %v, want bounded by the deadline reserve", elapsed)
+	}
+}
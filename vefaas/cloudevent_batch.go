@@ -0,0 +1,210 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+	"github.com/volcengine/vefaas-golang-runtime/utils"
+)
+
+func handleCloudEventBatch(handler interface{}) func(rw http.ResponseWriter, rq *http.Request) {
+	functionHandler := handler.(cloudeventBatchFunctionHandler)
+	return func(rw http.ResponseWriter, rq *http.Request) {
+		defer recoverFunction(rq.Context(), rw)
+
+		eventType := rq.Header.Get("X-Faas-Event-Type")
+		if eventType != events.EventTypeCloudEvent {
+			utils.SetInvalidEventTypeHeader(rw, eventType, events.EventTypeCloudEvent)
+			return
+		}
+
+		ctx := withInvocationContext(rq.Context(), rq)
+
+		batch, err := readCloudEvents(ctx, rq)
+		if err != nil {
+			utils.SetInvalidCloudEventHeader(rw, err)
+			return
+		}
+		annotateCloudEventBatchSpan(ctx, batch)
+
+		startTime := time.Now()
+		resp, err := functionHandler(ctx, batch)
+		writeEventResponse(ctx, rw, startTime, resp, err)
+	}
+}
+
+// isCloudEventBatch reports whether rq carries a batch of CloudEvents.
+func isCloudEventBatch(rq *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(rq.Header.Get("Content-Type"))
+	return err == nil && mediaType == cloudevents.ApplicationCloudEventsBatchJSON
+}
+
+// readCloudEvents reads the CloudEvents carried by rq, either a batch or a
+// single event as a batch of one.
+func readCloudEvents(ctx context.Context, rq *http.Request) ([]*events.CloudEvent, error) {
+	if !isCloudEventBatch(rq) {
+		event, err := readCloudEvent(ctx, rq)
+		if err != nil {
+			return nil, err
+		}
+		return []*events.CloudEvent{event}, nil
+	}
+
+	return readCloudEventBatch(rq)
+}
+
+// readCloudEventBatch reads the batch of CloudEvents in the
+// application/cloudevents-batch+json format carried by rq.
+func readCloudEventBatch(rq *http.Request) ([]*events.CloudEvent, error) {
+	body, err := utils.RawBodyFromHttpRequest(rq)
+	if err != nil {
+		return nil, err
+	}
+	var list []*cloudevents.Event
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+
+	batch := make([]*events.CloudEvent, 0, len(list))
+	for i, event := range list {
+		if event == nil {
+			return nil, fmt.Errorf("event %d of batch is null", i)
+		}
+		if err := event.Validate(); err != nil {
+			return nil, fmt.Errorf("event %d of batch is invalid, %v", i, err)
+		}
+		batch = append(batch, &events.CloudEvent{Event: event})
+	}
+
+	return batch, nil
+}
+
+// dispatchCloudEventBatch handles the events of batch with handler of single
+// events, at most parallelism at a time, and writes the report of the results.
+// The response status is 207 if any of the events failed.
+func dispatchCloudEventBatch(ctx context.Context, rw http.ResponseWriter, handler cloudeventFunctionHandler, batch []*events.CloudEvent, parallelism int) {
+	annotateCloudEventBatchSpan(ctx, batch)
+	startTime := time.Now()
+
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	report := &events.CloudEventBatchReport{Results: make([]events.CloudEventResult, len(batch))}
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, event := range batch {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, event *events.CloudEvent) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			report.Results[i] = dispatchCloudEvent(ctx, handler, event)
+		}(i, event)
+	}
+	wg.Wait()
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		writeEventResponse(ctx, rw, startTime, nil, err)
+		return
+	}
+	statusCode := http.StatusOK
+	if len(report.Failed()) > 0 {
+		statusCode = http.StatusMultiStatus
+	}
+	writeEventResponse(ctx, rw, startTime, &events.EventResponse{
+		StatusCode: statusCode,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       body,
+	}, nil)
+}
+
+// dispatchCloudEvent handles event of a batch with handler in its own span.
+func dispatchCloudEvent(ctx context.Context, handler cloudeventFunctionHandler, event *events.CloudEvent) events.CloudEventResult {
+	ctx, span := tracing.StartSpan(ctx, event.Type(), tracing.SpanKindConsumer)
+	span.SetAttribute("cloudevents.event_id", event.ID())
+	span.SetAttribute("cloudevents.event_source", event.Source())
+	span.SetAttribute("cloudevents.event_type", event.Type())
+
+	// The response of the event is only kept for the report.
+	buf := newBufferedResponseWriter()
+	rec := &responseRecorder{ResponseWriter: buf}
+	func() {
+		defer recoverFunction(ctx, rec)
+
+		startTime := time.Now()
+		resp, err := handler(ctx, event)
+		if resp != nil && resp.BodyStream != nil {
+			err = errors.New("streaming response is not supported in batches")
+			resp = nil
+		}
+		writeEventResponse(ctx, rec, startTime, resp, err)
+	}()
+	endInvocationSpan(span, rec)
+
+	result := events.CloudEventResult{
+		ID:           event.ID(),
+		Source:       event.Source(),
+		StatusCode:   rec.status(),
+		ErrorCode:    rec.Header().Get("X-Faas-Response-Error-Code"),
+		ErrorMessage: rec.Header().Get("X-Faas-Response-Error-Message"),
+	}
+	switch rec.Header().Get(retryableHeader) {
+	case "true":
+		result.Retryable = true
+		result.RetryAfter, _ = strconv.Atoi(rec.Header().Get(retryAfterHeader))
+	case "false":
+		result.Terminal = true
+	}
+	if !result.Failed() {
+		result.Reply, result.Body = decodeCloudEventReply(buf)
+	}
+
+	return result
+}
+
+// annotateCloudEventBatchSpan decorates the invocation span with the attributes
+// of batch.
+func annotateCloudEventBatchSpan(ctx context.Context, batch []*events.CloudEvent) {
+	span := tracing.SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	if len(batch) == 1 {
+		annotateCloudEventSpan(ctx, batch[0])
+		return
+	}
+
+	span.SetAttribute("cloudevents.batch_size", len(batch))
+	if len(batch) > 0 {
+		span.SetName(batch[0].Type())
+		span.SetAttribute("faas.trigger", cloudEventTrigger(batch[0].Type()))
+	}
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+func serveBatch(handler interface{}, rq *http.Request, opts ...Option) *httptest.ResponseRecorder {
+	rq.Header.Set("X-Faas-Request-Id", "req-1")
+	rec := httptest.NewRecorder()
+	newFunctionServer(handler, newOptions(opts...)).ServeHTTP(rec, rq)
+	return rec
+}
+
+func newTestBatch(t *testing.T, sources ...string) *http.Request {
+	batch := make([]*events.CloudEvent, 0, len(sources))
+	for _, source := range sources {
+		batch = append(batch, events.NewCloudEvent("test.event", source, nil))
+	}
+	rq, err := events.NewBatchRequest("http://localhost/", batch...)
+	if err != nil {
+		t.Fatalf("NewBatchRequest() error = %v", err)
+	}
+	return rq
+}
+
+func TestCloudEventBatchPerEvent(t *testing.T) {
+	handler := func(ctx context.Context, event *events.CloudEvent) (*events.EventResponse, error) {
+		switch event.Source() {
+		case "fail":
+			return nil, errors.New("boom")
+		case "panic":
+			panic("oops")
+		case "bad":
+			return nil, BadRequest("bad event")
+		}
+		return &events.EventResponse{StatusCode: http.StatusAccepted}, nil
+	}
+
+	rec := serveBatch(handler, newTestBatch(t, "ok", "fail", "panic", "bad"))
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want %d, body %s", rec.Code, http.StatusMultiStatus, rec.Body)
+	}
+	var report events.CloudEventBatchReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := []struct {
+		source string
+		status int
+		code   string
+	}{
+		{"ok", http.StatusAccepted, ""},
+		{"fail", http.StatusInternalServerError, "function_execution_error"},
+		{"panic", http.StatusInternalServerError, "function_panic"},
+		{"bad", http.StatusBadRequest, "bad_request"},
+	}
+	if len(report.Results) != len(want) {
+		t.Fatalf("results = %+v", report.Results)
+	}
+	for i, w := range want {
+		r := report.Results[i]
+		if r.Source != w.source || r.StatusCode != w.status || r.ErrorCode != w.code || r.ID == "" {
+			t.Errorf("result %d = %+v, want %+v", i, r, w)
+		}
+	}
+	if n := len(report.Failed()); n != 3 {
+		t.Errorf("Failed() = %d results, want 3", n)
+	}
+}
+
+func TestCloudEventBatchAllSucceeded(t *testing.T) {
+	handler := func(ctx context.Context, event *events.CloudEvent) (*events.EventResponse, error) {
+		return &events.EventResponse{}, nil
+	}
+
+	rec := serveBatch(handler, newTestBatch(t, "a", "b"))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status_code":200`) {
+		t.Errorf("response = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCloudEventBatchParallelism(t *testing.T) {
+	var running, peak int32
+	handler := func(ctx context.Context, event *events.CloudEvent) (*events.EventResponse, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return &events.EventResponse{}, nil
+	}
+
+	cases := []struct {
+		parallelism int
+		want        int32
+	}{
+		{1, 1},
+		{3, 3},
+	}
+	for _, c := range cases {
+		atomic.StoreInt32(&peak, 0)
+		rec := serveBatch(handler, newTestBatch(t, "a", "b", "c", "d", "e", "f"), WithBatchParallelism(c.parallelism))
+		if rec.Code != http.StatusOK {
+			t.Errorf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		if p := atomic.LoadInt32(&peak); p != c.want {
+			t.Errorf("parallelism %d: peak = %d, want %d", c.parallelism, p, c.want)
+		}
+	}
+}
+
+func TestCloudEventBatchHandler(t *testing.T) {
+	handler := func(ctx context.Context, batch []*events.CloudEvent) (*events.EventResponse, error) {
+		sources := make([]string, 0, len(batch))
+		for _, event := range batch {
+			sources = append(sources, event.Source())
+		}
+		return &events.EventResponse{Body: []byte(strings.Join(sources, ","))}, nil
+	}
+
+	rec := serveBatch(handler, newTestBatch(t, "a", "b"))
+	if rec.Code != http.StatusOK || rec.Body.String() != "a,b" {
+		t.Errorf("batch response = %d %s", rec.Code, rec.Body)
+	}
+
+	// Single events are handled as batches of one.
+	rq, _ := events.NewBinaryRequest("http://localhost/", events.NewCloudEvent("test.event", "c", nil))
+	rec = serveBatch(handler, rq)
+	if rec.Code != http.StatusOK || rec.Body.String() != "c" {
+		t.Errorf("single response = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCloudEventBatchAnyHandler(t *testing.T) {
+	handler := func(ctx context.Context, payload interface{}) (*events.EventResponse, error) {
+		batch, ok := payload.([]*events.CloudEvent)
+		if !ok {
+			return nil, errors.New("not a batch")
+		}
+		return &events.EventResponse{Body: []byte(batch[1].Source())}, nil
+	}
+
+	rec := serveBatch(handler, newTestBatch(t, "a", "b"))
+	if rec.Code != http.StatusOK || rec.Body.String() != "b" {
+		t.Errorf("response = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestCloudEventBatchInvalid(t *testing.T) {
+	handler := func(ctx context.Context, event *events.CloudEvent) (*events.EventResponse, error) {
+		return &events.EventResponse{}, nil
+	}
+
+	for _, body := range []string{`{}`, `[null]`, `[{"specversion":"1.0","id":"1"}]`} {
+		rq := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		rq.Header.Set("Content-Type", "application/cloudevents-batch+json; charset=utf-8")
+		rq.Header.Set("X-Faas-Event-Type", events.EventTypeCloudEvent)
+		rec := serveBatch(handler, rq)
+		if rec.Code != http.StatusBadRequest || rec.Header().Get("X-Faas-Response-Error-Code") != "invalid_cloud_event" {
+			t.Errorf("body %s: response = %d %s", body, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestCloudEventBatchResponses(t *testing.T) {
+	handler := func(ctx context.Context, event *events.CloudEvent) (*events.EventResponse, error) {
+		switch event.Source() {
+		case "reply":
+			return CloudEventResponse(events.NewCloudEvent("test.reply", "function", map[string]string{"id": event.ID()}))
+		case "fail":
+			return nil, errors.New("boom")
+		}
+		return &events.EventResponse{Body: []byte("handled " + event.Source())}, nil
+	}
+
+	rec := serveBatch(handler, newTestBatch(t, "reply", "body", "fail"))
+	var report events.CloudEventBatchReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Unmarshal() error = %v, body %s", err, rec.Body)
+	}
+	if len(report.Results) != 3 {
+		t.Fatalf("results = %+v", report.Results)
+	}
+	if r := report.Results[0]; r.Reply == nil || r.Reply.Type() != "test.reply" || r.Body != nil {
+		t.Errorf("result of reply = %+v", r)
+	} else if !strings.Contains(string(r.Reply.Data()), r.ID) {
+		t.Errorf("reply data = %s, want the event id %s", r.Reply.Data(), r.ID)
+	}
+	if r := report.Results[1]; r.Reply != nil || string(r.Body) != "handled body" {
+		t.Errorf("result of body = %+v", r)
+	}
+	if r := report.Results[2]; r.Reply != nil || r.Body != nil {
+		t.Errorf("result of failure = %+v, want no response kept", r)
+	}
+}
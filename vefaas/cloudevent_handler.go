@@ -17,6 +17,7 @@
 package vefaas
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -24,13 +25,12 @@ import (
 	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
 	"github.com/volcengine/vefaas-golang-runtime/events"
 	"github.com/volcengine/vefaas-golang-runtime/utils"
-	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
 )
 
-func handleCloudEvent(handler interface{}) func(rw http.ResponseWriter, rq *http.Request) {
+func handleCloudEvent(handler interface{}, o *options) func(rw http.ResponseWriter, rq *http.Request) {
 	functionHandler := handler.(cloudeventFunctionHandler)
 	return func(rw http.ResponseWriter, rq *http.Request) {
-		defer utils.RecoverFunc(rw, nil)
+		defer recoverFunction(rq.Context(), rw)
 
 		eventType := rq.Header.Get("X-Faas-Event-Type")
 		if eventType != events.EventTypeCloudEvent {
@@ -38,46 +38,38 @@ func handleCloudEvent(handler interface{}) func(rw http.ResponseWriter, rq *http
 			return
 		}
 
-		ctx := rq.Context()
-		ctx = vefaascontext.WithRequestIdContext(ctx, rq)
-		ctx = vefaascontext.WithAccessKeyIdContext(ctx, rq)
-		ctx = vefaascontext.WithSecretAccessKeyContext(ctx, rq)
-		ctx = vefaascontext.WithSessionTokenContext(ctx, rq)
+		ctx := withInvocationContext(rq.Context(), rq)
 
-		msg := cehttp.NewMessageFromHttpRequest(rq)
-		event, err := binding.ToEvent(ctx, msg)
-		if err != nil {
-			utils.SetInvalidCloudEventHeader(rw, err)
+		if isCloudEventBatch(rq) {
+			batch, err := readCloudEventBatch(rq)
+			if err != nil {
+				utils.SetInvalidCloudEventHeader(rw, err)
+				return
+			}
+			dispatchCloudEventBatch(ctx, rw, functionHandler, batch, o.batchParallelism)
 			return
 		}
 
-		startTime := time.Now()
-		resp, err := functionHandler(ctx, &events.CloudEvent{Event: event})
-
-		utils.SetExecutionDurationHeader(rw, startTime)
-
+		payload, err := readCloudEvent(ctx, rq)
 		if err != nil {
-			utils.SetFunctionExecutionErrorHeader(rw, err)
-			return
-		}
-		if resp == nil {
-			utils.SetFunctionNoResponseErrorHeader(rw)
+			utils.SetInvalidCloudEventHeader(rw, err)
 			return
 		}
+		annotateCloudEventSpan(ctx, payload)
 
-		if resp.Headers != nil {
-			for k, v := range resp.Headers {
-				rw.Header().Set(k, v)
-			}
-		}
-		// In case user set this response header, we rewrite it here.
-		utils.SetExecutionDurationHeader(rw, startTime)
+		startTime := time.Now()
+		resp, err := functionHandler(ctx, payload)
+		writeEventResponse(ctx, rw, startTime, resp, err)
+	}
+}
 
-		if resp.StatusCode != 0 {
-			rw.WriteHeader(resp.StatusCode)
-		}
-		if resp.Body != nil {
-			_, _ = rw.Write(resp.Body)
-		}
+// readCloudEvent reads the CloudEvent carried by rq, in either binary or
+// structured mode.
+func readCloudEvent(ctx context.Context, rq *http.Request) (*events.CloudEvent, error) {
+	event, err := binding.ToEvent(ctx, cehttp.NewMessageFromHttpRequest(rq))
+	if err != nil {
+		return nil, err
 	}
+
+	return &events.CloudEvent{Event: event}, nil
 }
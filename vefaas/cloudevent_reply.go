@@ -0,0 +1,107 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+// CloudEventResponse creates the response replying with event in binary mode, the
+// event attributes in Ce-* headers and the data in the body, for functions acting
+// as Knative-style event transformers.
+//
+// CloudEvent handlers may also return the reply directly:
+//
+//	func(context.Context, *events.CloudEvent) (*events.CloudEvent, error)
+//
+// which is encoded with CloudEventResponse, or answered with 202 if the reply is
+// nil.
+func CloudEventResponse(event *events.CloudEvent) (*events.EventResponse, error) {
+	return encodeCloudEventResponse(binding.WithForceBinary(context.Background()), event)
+}
+
+// StructuredCloudEventResponse creates the response replying with event in
+// structured mode, the whole event encoded as application/cloudevents+json.
+func StructuredCloudEventResponse(event *events.CloudEvent) (*events.EventResponse, error) {
+	return encodeCloudEventResponse(binding.WithForceStructured(context.Background()), event)
+}
+
+func encodeCloudEventResponse(ctx context.Context, event *events.CloudEvent) (*events.EventResponse, error) {
+	if event == nil || event.Event == nil {
+		return nil, fmt.Errorf("reply cloudevent is nil")
+	}
+	if err := event.Validate(); err != nil {
+		return nil, fmt.Errorf("reply cloudevent is invalid, %w", err)
+	}
+
+	w := newBufferedResponseWriter()
+	if err := cehttp.WriteResponseWriter(ctx, binding.ToMessage(event.Event), http.StatusOK, w); err != nil {
+		return nil, fmt.Errorf("failed to encode reply cloudevent, %w", err)
+	}
+
+	headers := make(map[string]string, len(w.header))
+	for k := range w.header {
+		headers[k] = w.header.Get(k)
+	}
+	return &events.EventResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       w.body.Bytes(),
+	}, nil
+}
+
+// replyCloudEventHandler adapts the handler replying with CloudEvents.
+func replyCloudEventHandler(handler cloudeventReplyFunctionHandler) cloudeventFunctionHandler {
+	return func(ctx context.Context, event *events.CloudEvent) (*events.EventResponse, error) {
+		reply, err := handler(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+		if reply == nil {
+			return &events.EventResponse{StatusCode: http.StatusAccepted}, nil
+		}
+
+		return CloudEventResponse(reply)
+	}
+}
+
+// decodeCloudEventReply decodes the reply CloudEvent from the response in w, or
+// returns the response body if it's not a CloudEvent.
+func decodeCloudEventReply(w *bufferedResponseWriter) (*cloudevents.Event, []byte) {
+	body := w.body.Bytes()
+	message := cehttp.NewMessage(w.header, ioutil.NopCloser(bytes.NewReader(body)))
+	if message.ReadEncoding() == binding.EncodingUnknown {
+		if len(body) == 0 {
+			return nil, nil
+		}
+		return nil, body
+	}
+	event, err := binding.ToEvent(context.Background(), message)
+	if err != nil {
+		return nil, body
+	}
+
+	return event, nil
+}
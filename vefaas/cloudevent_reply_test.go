@@ -0,0 +1,100 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+func invokeReplyHandler(t *testing.T, handler interface{}) *http.Response {
+	rq, err := events.NewBinaryRequest("http://localhost/", events.NewCloudEvent("order.created", "orders", map[string]string{"id": "1"}))
+	if err != nil {
+		t.Fatalf("NewBinaryRequest() error = %v", err)
+	}
+	rec := httptest.NewRecorder()
+	newFunctionServer(handler, newOptions()).ServeHTTP(rec, rq)
+	return rec.Result()
+}
+
+func TestCloudEventReply(t *testing.T) {
+	transform := func(ctx context.Context, event *events.CloudEvent) *events.CloudEvent {
+		reply := events.NewCloudEvent("order.enriched", "enricher", map[string]string{"from": event.ID()})
+		reply.SetExtension("orderid", "1")
+		return reply
+	}
+	handlers := map[string]interface{}{
+		"signature": func(ctx context.Context, event *events.CloudEvent) (*events.CloudEvent, error) {
+			return transform(ctx, event), nil
+		},
+		"binary": func(ctx context.Context, event *events.CloudEvent) (*events.EventResponse, error) {
+			return CloudEventResponse(transform(ctx, event))
+		},
+		"structured": func(ctx context.Context, event *events.CloudEvent) (*events.EventResponse, error) {
+			return StructuredCloudEventResponse(transform(ctx, event))
+		},
+	}
+	for name, handler := range handlers {
+		resp := invokeReplyHandler(t, handler)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: status = %d", name, resp.StatusCode)
+			continue
+		}
+		msg := cehttp.NewMessageFromHttpResponse(resp)
+		wantEncoding := binding.EncodingBinary
+		if name == "structured" {
+			wantEncoding = binding.EncodingStructured
+		}
+		if msg.ReadEncoding() != wantEncoding {
+			t.Errorf("%s: encoding = %v, want %v", name, msg.ReadEncoding(), wantEncoding)
+		}
+		reply, err := binding.ToEvent(context.Background(), msg)
+		if err != nil {
+			t.Errorf("%s: ToEvent() error = %v", name, err)
+			continue
+		}
+		if reply.Type() != "order.enriched" || reply.Extensions()["orderid"] != "1" {
+			t.Errorf("%s: reply = %v", name, reply)
+		}
+	}
+}
+
+func TestCloudEventReplyNone(t *testing.T) {
+	handler := func(ctx context.Context, event *events.CloudEvent) (*events.CloudEvent, error) {
+		return nil, nil
+	}
+	if resp := invokeReplyHandler(t, handler); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+}
+
+func TestCloudEventReplyInvalid(t *testing.T) {
+	handler := func(ctx context.Context, event *events.CloudEvent) (*events.CloudEvent, error) {
+		reply := events.NewCloudEvent("order.enriched", "enricher", nil)
+		reply.SetType("")
+		return reply, nil
+	}
+	resp := invokeReplyHandler(t, handler)
+	if resp.StatusCode != http.StatusInternalServerError || resp.Header.Get("X-Faas-Response-Error-Code") != "function_execution_error" {
+		t.Errorf("response = %d %v", resp.StatusCode, resp.Header)
+	}
+}
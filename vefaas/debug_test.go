@@ -0,0 +1,118 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/utils"
+)
+
+func TestDebugMode(t *testing.T) {
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		if r.Path == "/panic" {
+			panic("boom")
+		}
+		return nil, fmt.Errorf("query order: %w", errors.New("connection refused"))
+	}
+	eventType, functionHandler := validateHandler(handler)
+	server := &functionServer{handleFunc: buildHandler(eventType, functionHandler, nil, &options{}), debugSecret: "s3cret"}
+
+	invoke := func(path, token string) (http.Header, *utils.DebugInfo) {
+		rq := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			rq.Header.Set(utils.DebugHeader, token)
+		}
+		rw := httptest.NewRecorder()
+		server.ServeHTTP(rw, rq)
+
+		var body struct {
+			Debug *utils.DebugInfo `json:"debug"`
+		}
+		if err := json.Unmarshal(rw.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode body %q: %v", rw.Body.String(), err)
+		}
+		return rw.Header(), body.Debug
+	}
+
+	// Production behaviour without or with a wrong secret.
+	for _, token := range []string{"", "wrong"} {
+		header, info := invoke("/panic", token)
+		if info != nil || header.Get(utils.DebugHeader) != "" {
+			t.Errorf("debug info exposed with token %q: %+v", token, info)
+		}
+	}
+
+	header, info := invoke("/panic", "s3cret")
+	if header.Get(utils.DebugHeader) != "true" || info == nil {
+		t.Fatalf("debug info missing")
+	}
+	if info.Panic != "boom" || len(info.Stack) == 0 || !strings.Contains(info.Stack[0], "TestDebugMode") {
+		t.Errorf("unexpected panic debug info %+v", info)
+	}
+	for _, frame := range info.Stack {
+		if strings.Contains(frame, "net/http") || strings.Contains(frame, "recoverFunction") {
+			t.Errorf("stack not trimmed: %v", info.Stack)
+		}
+	}
+
+	server.debug = true
+	_, info = invoke("/error", "")
+	if info == nil || len(info.Errors) != 2 || !strings.Contains(info.Errors[1], "connection refused") {
+		t.Errorf("unexpected error debug info %+v", info)
+	}
+}
+
+func TestDebugModeNotFromResponse(t *testing.T) {
+	var secret string
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		secret = r.Headers[utils.DebugHeader]
+		return nil, errors.New("connection refused")
+	}
+	eventType, functionHandler := validateHandler(handler)
+	server := &functionServer{handleFunc: buildHandler(eventType, functionHandler, nil, &options{}), debugSecret: "s3cret"}
+
+	// The debug response header, which handlers are free to set, must not turn
+	// debug mode on.
+	rq := httptest.NewRequest(http.MethodGet, "/error", nil)
+	rq.Header.Set(utils.DebugHeader, "wrong")
+	rw := httptest.NewRecorder()
+	rw.Header().Set(utils.DebugHeader, "true")
+	server.ServeHTTP(rw, rq)
+	if strings.Contains(rw.Body.String(), `"debug"`) {
+		t.Errorf("debug info exposed: %s", rw.Body.String())
+	}
+
+	rq = httptest.NewRequest(http.MethodGet, "/error", nil)
+	rq.Header.Set(utils.DebugHeader, "s3cret")
+	rw = httptest.NewRecorder()
+	server.ServeHTTP(rw, rq)
+	if !strings.Contains(rw.Body.String(), `"debug"`) {
+		t.Errorf("debug info missing: %s", rw.Body.String())
+	}
+	if secret != "" {
+		t.Errorf("debug secret passed to the handler: %q", secret)
+	}
+}
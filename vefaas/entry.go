@@ -18,18 +18,21 @@ package vefaas
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"math/rand"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/volcengine/vefaas-golang-runtime/events"
-	"github.com/volcengine/vefaas-golang-runtime/version"
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+	"github.com/volcengine/vefaas-golang-runtime/utils"
 )
 
 var (
@@ -51,12 +54,30 @@ const (
 //
 // - func(context.Context, *events.CloudEvent) (*events.EventResponse, error)
 // for handling CloudEvent request, like those from timer trigger, tos trigger,
-// kafka trigger, etc.
+// kafka trigger, etc. Batches of CloudEvents in the
+// application/cloudevents-batch+json format are dispatched to the handler event
+// by event, see WithBatchParallelism, and answered with a
+// events.CloudEventBatchReport, carrying the response or reply of each event.
+//
+// - func(context.Context, *events.CloudEvent) (*events.CloudEvent, error)
+// for handling CloudEvent request and replying with a CloudEvent, see
+// CloudEventResponse.
+//
+// - func(context.Context, []*events.CloudEvent) (*events.EventResponse, error)
+// for handling batches of CloudEvents at once, single CloudEvents are handled
+// as batches of one.
 //
 // - func(context.Context, interface{}) (*events.EventResponse, error)
 // for handling requests of any type, especially for those business that
 // handle both regular http requests and CloudEvent requests, and the developer
-// can use type assertion to distinguish and process them.
+// can use type assertion to distinguish and process them. Batches of CloudEvents
+// are passed as []*events.CloudEvent.
+//
+// - func(context.Context, *websocket.Conn) error
+// for handling WebSocket connections upgraded from the http requests of HTTP
+// triggers. The connection is closed with websocket.CloseGoingAway when the
+// function times out or the runtime shuts down, and the context is cancelled.
+// See WithWebSocketSubprotocols for negotiating subprotocols.
 func Start(handler interface{}) {
 	StartWithInitializer(handler, nil)
 }
@@ -71,71 +92,157 @@ func Start(handler interface{}) {
 // database, setup the http/rpc client for downstream services, etc.
 // Currently the supported initializer signatures are:
 // - func(context.Context) error
+//
+// The context passed to initializer carries the request id and the temporary
+// credentials of the initialization request, see package vefaascontext.
 func StartWithInitializer(handler interface{}, initializer interface{}) {
-	rand.Seed(time.Now().UTC().UnixNano())
+	StartWithOptions(handler, WithInitializer(initializer))
+}
 
-	// Validate handler.
-	eventType, functionHandler := validateHandler(handler)
+// StartWithOptions starts vefaas runtime server with provided handler and
+// options, like WithInitializer, WithTraceExporter, etc.
+//
+// See Start for the supported handler signatures.
+//
+// For debugging, the function can be invoked once without listening by running
+// the binary with "--invoke event.json", or the VEFAAS_INVOKE_EVENT environment
+// variable, set to either a file path or inline json. The event is a structured
+// mode CloudEvent, or an http request like:
+//
+//	{"method": "POST", "path": "/orders", "query": {}, "headers": {}, "body": {}}
+//
+// The initializer runs first, then the response of the invocation is printed to
+// stdout, and the process exits with a non-zero code if the invocation failed.
+func StartWithOptions(handler interface{}, opts ...Option) {
+	rand.Seed(time.Now().UTC().UnixNano())
+	o := newOptions(opts...)
 
-	// Validate initializer.
-	functionInitializer := validateInitializer(initializer)
+	server := newFunctionServer(handler, o)
 
 	// Initialize metadata.
+	loadFunctionMetadata()
+
+	// Invoke the function once without listening, if asked to.
+	if source, ok := invokeEventSource(os.Args[1:]); ok {
+		os.Exit(invokeOnce(server, source, os.Stdout))
+	}
+
+	serve(server, o)
+}
+
+// loadFunctionMetadata loads the metadata of the function from the environment.
+func loadFunctionMetadata() {
 	if s := os.Getenv("_FAAS_FUNC_TIMEOUT"); s != "" {
 		if tmp, err := strconv.Atoi(s); err == nil {
 			requestTimeoutSecond = tmp
 		}
 	}
+}
 
+// serve serves the function until SIGINT or SIGTERM, then shuts down gracefully.
+func serve(server *functionServer, o *options) {
 	// Start http server.
 	if s := os.Getenv("_FAAS_RUNTIME_PORT"); s != "" {
 		listenPort = s
 	}
-	listener, err := net.Listen("tcp", ":"+listenPort)
+	listener, adminListener, err := serverListeners(o)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to listen port with tcp, %v.\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to listen, %v.\n", err)
 		os.Exit(startServerExitCode)
 	}
 	defer listener.Close()
-	server := &http.Server{
-		Handler: functionServer{
-			initializer: functionInitializer,
-			handleFunc:  buildHandler(eventType, functionHandler),
-		},
+	server.internalOnAdmin = adminListener != nil
+	httpServer := &http.Server{
+		Handler: server,
+	}
+	if o.h2c {
+		if err := configureH2C(httpServer); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to enable h2c, %v.\n", err)
+			os.Exit(startServerExitCode)
+		}
 	}
 
 	go func() {
-		err := server.Serve(listener)
+		err := httpServer.Serve(listener)
 		if err != http.ErrServerClosed {
 			fmt.Fprintf(os.Stderr, "Server exited unexpectedly, %v.\n", err)
 			os.Exit(startServerExitCode)
 		}
 	}()
 
+	// Start admin server, if asked to.
+	var adminServer *http.Server
+	if adminListener != nil {
+		defer adminListener.Close()
+		adminServer = &http.Server{
+			Handler: server.adminHandler(),
+		}
+
+		go func() {
+			err := adminServer.Serve(adminListener)
+			if err != http.ErrServerClosed {
+				fmt.Fprintf(os.Stderr, "Admin server exited unexpectedly, %v.\n", err)
+				os.Exit(startServerExitCode)
+			}
+		}()
+	}
+
 	// Graceful shutdown.
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
 	<-stopChan
+	atomic.StoreInt32(&server.shuttingDown, 1)
 
 	// Shutdown http server to close open listeners and idle connections,
 	// wait active connections to return to idle and then shut down,
 	// and refuse further connections and requests.
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(requestTimeoutSecond)*time.Second)
 	defer cancel()
-	err = server.Shutdown(ctx)
+	err = httpServer.Shutdown(ctx)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Shutdown http server error, %v.\n", err)
 	}
+
+	// The asynchronous invocations outlive their requests.
+	err = server.waitAsync(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Wait async invocations error, %v.\n", err)
+	}
+
+	// The WebSocket connections are hijacked, thus not drained by the http server.
+	err = server.webSockets.closeAll(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Close websocket connections error, %v.\n", err)
+	}
+
+	// The admin server is the last to go, to answer the health checks while
+	// draining the invocations.
+	if adminServer != nil {
+		err = adminServer.Shutdown(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Shutdown admin server error, %v.\n", err)
+		}
+	}
+
+	// Flush the spans of the invocations finished during shutdown.
+	err = server.tracer.Shutdown(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Shutdown tracer error, %v.\n", err)
+	}
 }
 
-func buildHandler(eventType string, handler interface{}) func(rw http.ResponseWriter, rq *http.Request) {
+func buildHandler(eventType string, handler interface{}, webSockets *webSocketTracker, o *options) func(rw http.ResponseWriter, rq *http.Request) {
 	switch eventType {
 	case events.EventTypeHTTP:
-		return handleHttpEvent(handler)
+		return handleHttpEvent(handler, o)
 	case events.EventTypeCloudEvent:
-		return handleCloudEvent(handler)
+		return handleCloudEvent(handler, o)
+	case events.EventTypeCloudEventBatch:
+		return handleCloudEventBatch(handler)
 	case events.EventTypeAny:
-		return handleAnyEvent(handler)
+		return handleAnyEvent(handler, o)
+	case events.EventTypeWebSocket:
+		return handleWebSocket(handler, webSockets, o.webSocketSubprotocols)
 	default:
 		return func(rw http.ResponseWriter, rq *http.Request) {
 			rw.WriteHeader(http.StatusBadRequest)
@@ -143,40 +250,160 @@ func buildHandler(eventType string, handler interface{}) func(rw http.ResponseWr
 	}
 }
 
+// NewHandler creates the http.Handler serving function invocations with provided
+// handler and options, exactly the same as the one served by StartWithOptions,
+// including the internal endpoints like /v1/initialize. It's useful for testing
+// functions in process, see package vefaastest.
+//
+// See Start for the supported handler signatures.
+func NewHandler(handler interface{}, opts ...Option) http.Handler {
+	return newFunctionServer(handler, newOptions(opts...))
+}
+
+func newFunctionServer(handler interface{}, o *options) *functionServer {
+	// Validate handler.
+	eventType, functionHandler := validateHandler(handler)
+
+	functionHandler = idempotentHandler(eventType, functionHandler, o)
+
+	s := newServer(o)
+	s.handleFunc = buildHandler(eventType, functionHandler, s.webSockets, o)
+
+	return s
+}
+
+// newServer creates the functionServer with the settings of o, serving the
+// internal requests, the invocations are left to the handleFunc set by caller.
+func newServer(o *options) *functionServer {
+	// Validate initializer.
+	functionInitializer := validateInitializer(o.initializer)
+
+	return &functionServer{
+		initializer: functionInitializer,
+		webSockets:  newWebSocketTracker(),
+		tracer:      tracing.NewTracer(o.traceExporter),
+		renderer:    o.errorRenderer,
+		debug:       o.debug,
+		debugSecret: o.debugSecret,
+
+		internalToken:        o.internalToken,
+		internalLoopbackOnly: o.internalLoopbackOnly,
+
+		async:          o.async,
+		asyncOnSuccess: o.asyncOnSuccess,
+		asyncOnFailure: o.asyncOnFailure,
+	}
+}
+
 type functionServer struct {
+	// metrics goes first for the 64-bit alignment of atomic operations.
+	metrics invocationMetrics
+
 	initializer interface{}
 	handleFunc  func(http.ResponseWriter, *http.Request)
+	webSockets  *webSocketTracker
+	tracer      *tracing.Tracer
+	renderer    utils.ErrorRenderer
+	debug       bool
+	debugSecret string
+
+	internalToken        string
+	internalLoopbackOnly bool
+	// internalOnAdmin disables the control calls on the function port, as they
+	// are served on the admin listener.
+	internalOnAdmin bool
+
+	async          bool
+	asyncOnSuccess string
+	asyncOnFailure string
+	// asyncPending tracks the running asynchronous invocations.
+	asyncPending sync.WaitGroup
+
+	initMu      sync.Mutex
+	initialized bool
+	// initDone mirrors initialized for the lock free health checks.
+	initDone int32
+
+	shuttingDown int32
 }
 
-func (s functionServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	if r.Header.Get("X-Faas-Internal-Request") == "true" {
-		switch r.URL.Path {
-		case "/v1/initialize":
-			switch r.Method {
-			case http.MethodPost:
-				err := initializeFunction(context.Background(), s.initializer)
-				if err != nil {
-					rw.WriteHeader(http.StatusInternalServerError)
-				} else {
-					rw.WriteHeader(http.StatusOK)
-				}
-			default:
-				rw.WriteHeader(http.StatusMethodNotAllowed)
-			}
-		case "/v1/version":
-			switch r.Method {
-			case http.MethodGet:
-				rw.WriteHeader(http.StatusOK)
-				_, _ = rw.Write([]byte(version.Version))
-			default:
-				rw.WriteHeader(http.StatusMethodNotAllowed)
-			}
-		default:
-			rw.WriteHeader(http.StatusNotFound)
+func (s *functionServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(internalRequestHeader) != "" {
+		if s.isInternalRequest(r) {
+			s.serveInternal(rw, r)
+			return
+		}
+		// Spoofed internal requests are handled as ordinary user traffic,
+		// without the credentials they carry.
+		r.Header.Del(internalRequestHeader)
+		r.Header.Del(internalTokenHeader)
+		for _, h := range credentialHeaders {
+			r.Header.Del(h)
 		}
+	}
 
+	// Echo request id, so that callers can correlate the response, including
+	// the error responses generated by runtime.
+	if id := r.Header.Get("X-Faas-Request-Id"); id != "" {
+		rw.Header().Set("X-Faas-Request-Id", id)
+	}
+	debug := s.debugEnabled(r)
+	if debug {
+		rw.Header().Set(utils.DebugHeader, "true")
+	}
+	// The debug secret is meant for the runtime only.
+	r.Header.Del(utils.DebugHeader)
+
+	if s.async && isAsyncInvocation(r) {
+		s.serveAsync(rw, r, debug)
 		return
 	}
+	s.invoke(rw, r, debug)
+}
+
+// invoke handles the invocation r, bounded by the function timeout. The error
+// responses carry the debug information if debug is set.
+func (s *functionServer) invoke(rw http.ResponseWriter, r *http.Request, debug bool) {
+	// The invocation is bounded by the function timeout, so that handlers and
+	// the downstream calls can tell the remaining time from the context.
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(requestTimeoutSecond)*time.Second)
+	defer cancel()
+	ctx, span := startInvocationSpan(s.tracer, r.WithContext(ctx))
+	rec := &responseRecorder{ResponseWriter: rw, renderer: s.renderer, debug: debug}
+	start := s.metrics.start()
+	s.handleFunc(rec, r.WithContext(ctx))
+	s.metrics.end(start, rec)
+	endInvocationSpan(span, rec)
+}
+
+// initialize runs the function initializer, unless it has been executed successfully.
+func (s *functionServer) initialize(ctx context.Context) error {
+	s.initMu.Lock()
+	defer s.initMu.Unlock()
+
+	err := initializeFunction(ctx, s.initializer, &s.initialized)
+	if s.initialized {
+		atomic.StoreInt32(&s.initDone, 1)
+	}
+
+	return err
+}
+
+// isInitialized reports whether the function is ready to handle invocations.
+func (s *functionServer) isInitialized() bool {
+	return s.initializer == nil || atomic.LoadInt32(&s.initDone) != 0
+}
+
+// debugEnabled reports whether r should be handled in debug mode, either enabled
+// for all requests or toggled by the X-Faas-Debug header carrying the secret.
+func (s *functionServer) debugEnabled(r *http.Request) bool {
+	if s.debug {
+		return true
+	}
+	if s.debugSecret == "" {
+		return false
+	}
+	token := r.Header.Get(utils.DebugHeader)
 
-	s.handleFunc(rw, r)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.debugSecret)) == 1
 }
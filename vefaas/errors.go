@@ -0,0 +1,268 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaas
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/utils"
+)
+
+const (
+	// retryableHeader tells the trigger whether to retry the failed invocation,
+	// absent if the handler didn't say.
+	retryableHeader = "X-Faas-Response-Error-Retryable"
+
+	// retryAfterHeader is the delay in seconds before retrying the failed invocation.
+	retryAfterHeader = "X-Faas-Retry-After"
+)
+
+// Error is an error returned from function handler that is reported to the
+// caller with its own status code and error code, rather than the generic 500
+// function_execution_error.
+//
+// The runtime finds Error with errors.As, so it can be wrapped by other errors:
+//
+//	return nil, fmt.Errorf("validate order: %w", vefaas.BadRequest("missing field %q", "id"))
+type Error struct {
+	// StatusCode is the http status code of the response.
+	StatusCode int
+
+	// Code is the machine readable error code, set as X-Faas-Response-Error-Code header.
+	Code string
+
+	// Message is the human readable error message, set as X-Faas-Response-Error-Message header.
+	Message string
+
+	// Details is optional additional information, rendered in the response body.
+	Details interface{}
+
+	// Retryable reports whether the caller may retry the request.
+	Retryable bool
+
+	// Terminal reports whether the request must not be retried, like a poison
+	// message which fails however many times it's redelivered.
+	Terminal bool
+
+	// RetryAfter is the optional delay before retrying a retryable request.
+	RetryAfter time.Duration
+
+	// Err is the optional underlying cause, which is not exposed to the caller.
+	Err error
+}
+
+// NewError creates an Error with the given status code, error code and message.
+func NewError(statusCode int, code string, format string, args ...interface{}) *Error {
+	return &Error{
+		StatusCode: statusCode,
+		Code:       code,
+		Message:    fmt.Sprintf(format, args...),
+	}
+}
+
+// BadRequest creates a 400 Error with error code bad_request.
+func BadRequest(format string, args ...interface{}) *Error {
+	return NewError(http.StatusBadRequest, "bad_request", format, args...)
+}
+
+// Unauthorized creates a 401 Error with error code unauthorized.
+func Unauthorized(format string, args ...interface{}) *Error {
+	return NewError(http.StatusUnauthorized, "unauthorized", format, args...)
+}
+
+// Forbidden creates a 403 Error with error code forbidden.
+func Forbidden(format string, args ...interface{}) *Error {
+	return NewError(http.StatusForbidden, "forbidden", format, args...)
+}
+
+// NotFound creates a 404 Error with error code not_found.
+func NotFound(format string, args ...interface{}) *Error {
+	return NewError(http.StatusNotFound, "not_found", format, args...)
+}
+
+// Conflict creates a 409 Error with error code conflict.
+func Conflict(format string, args ...interface{}) *Error {
+	return NewError(http.StatusConflict, "conflict", format, args...)
+}
+
+// TooManyRequests creates a retryable 429 Error with error code too_many_requests.
+func TooManyRequests(format string, args ...interface{}) *Error {
+	e := NewError(http.StatusTooManyRequests, "too_many_requests", format, args...)
+	e.Retryable = true
+	return e
+}
+
+// InternalError creates a 500 Error with error code internal_error.
+func InternalError(format string, args ...interface{}) *Error {
+	return NewError(http.StatusInternalServerError, "internal_error", format, args...)
+}
+
+// ServiceUnavailable creates a retryable 503 Error with error code service_unavailable.
+func ServiceUnavailable(format string, args ...interface{}) *Error {
+	e := NewError(http.StatusServiceUnavailable, "service_unavailable", format, args...)
+	e.Retryable = true
+	return e
+}
+
+// Retryable wraps err to signal the trigger to retry the event, or the caller to
+// retry the request, as the failure is transient, like a timeout of a downstream
+// service. The behaviour by trigger is
+//   - http triggers: the caller gets 503 with Retry-After if set, which is
+//     retried by HTTP clients like Transport,
+//   - message queue triggers (kafka, bmq, rocketmq): the event is redelivered,
+//     after the delay of X-Faas-Retry-After if set,
+//   - other CloudEvent triggers (timer, tos, sns, tls): the event is retried
+//     within the retry policy of the trigger,
+//   - batches dispatched event by event: the result of the event in
+//     events.CloudEventBatchReport is marked retryable,
+//   - asynchronous invocations: the result is delivered to the on-failure
+//     destination, carrying the headers.
+//
+// The status code and error code of an Error wrapped by err are kept, otherwise
+// they are 503 and function_retryable_error. Use WithRetryAfter to set the delay.
+func Retryable(err error) *Error {
+	e := wrapError(err, http.StatusServiceUnavailable, "function_retryable_error", "Function returns retryable error")
+	e.Retryable = true
+	e.Terminal = false
+	return e
+}
+
+// Terminal wraps err to signal the trigger not to retry the event, or the caller
+// not to retry the request, as the failure is permanent, like a malformed message.
+// The behaviour by trigger is
+//   - http triggers: the caller gets the error as is,
+//   - message queue triggers (kafka, bmq, rocketmq): the event is skipped, or
+//     sent to the dead letter queue if configured, instead of redelivered,
+//   - other CloudEvent triggers (timer, tos, sns, tls): the event is dropped
+//     without retry,
+//   - batches dispatched event by event: the result of the event in
+//     events.CloudEventBatchReport is marked terminal,
+//   - asynchronous invocations: the result is delivered to the on-failure
+//     destination, carrying the headers.
+//
+// The status code and error code of an Error wrapped by err are kept, otherwise
+// they are 500 and function_terminal_error.
+//
+// Errors wrapped by neither Retryable nor Terminal are retried according to the
+// retry policy of the trigger.
+func Terminal(err error) *Error {
+	e := wrapError(err, http.StatusInternalServerError, "function_terminal_error", "Function returns terminal error")
+	e.Retryable = false
+	e.Terminal = true
+	e.RetryAfter = 0
+	return e
+}
+
+// wrapError creates an Error wrapping err, which copies the Error in the chain
+// of err if any.
+func wrapError(err error, statusCode int, code, message string) *Error {
+	var fe *Error
+	if errors.As(err, &fe) {
+		e := *fe
+		if err != error(fe) {
+			e.Err = err
+		}
+		return &e
+	}
+	if err != nil {
+		message = fmt.Sprintf("%s, %v.", message, err)
+	} else {
+		message += "."
+	}
+	return &Error{StatusCode: statusCode, Code: code, Message: message, Err: err}
+}
+
+// WithDetails sets the details of e and returns e.
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+// WithRetryable sets whether e is retryable and returns e.
+func (e *Error) WithRetryable(retryable bool) *Error {
+	e.Retryable = retryable
+	return e
+}
+
+// WithRetryAfter marks e retryable after delay and returns e.
+func (e *Error) WithRetryAfter(delay time.Duration) *Error {
+	e.Retryable = true
+	e.Terminal = false
+	e.RetryAfter = delay
+	return e
+}
+
+// Wrap sets the underlying cause of e and returns e.
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap returns the underlying cause of e.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// writeError writes e as the response, with error headers and a body rendered
+// by the configured error renderer.
+func writeError(rw http.ResponseWriter, e *Error) {
+	statusCode := e.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+	}
+	code := e.Code
+	if code == "" {
+		code = "function_execution_error"
+	}
+	if e.Retryable {
+		rw.Header().Set(retryableHeader, "true")
+		if e.RetryAfter > 0 {
+			seconds := strconv.Itoa(retryAfterSeconds(e.RetryAfter))
+			rw.Header().Set(retryAfterHeader, seconds)
+			rw.Header().Set("Retry-After", seconds)
+		}
+	} else if e.Terminal {
+		rw.Header().Set(retryableHeader, "false")
+	}
+
+	utils.WriteErrorResponse(rw, &utils.ErrorResponse{
+		StatusCode: statusCode,
+		Code:       code,
+		Message:    e.Message,
+		Details:    e.Details,
+		Retryable:  e.Retryable,
+		Debug:      utils.ErrorDebugInfo(e),
+	})
+}
+
+// retryAfterSeconds rounds delay up to whole seconds.
+func retryAfterSeconds(delay time.Duration) int {
+	return int(math.Ceil(delay.Seconds()))
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/utils"
+)
+
+func TestFunctionError(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		status    int
+		code      string
+		retryable bool
+	}{
+		{"bad request", BadRequest("missing field %q", "id"), http.StatusBadRequest, "bad_request", false},
+		{"wrapped not found", fmt.Errorf("load order: %w", NotFound("order not found")), http.StatusNotFound, "not_found", false},
+		{"unavailable", ServiceUnavailable("db is down"), http.StatusServiceUnavailable, "service_unavailable", true},
+		{"custom", NewError(http.StatusPaymentRequired, "quota_exceeded", "quota exceeded"), http.StatusPaymentRequired, "quota_exceeded", false},
+		{"plain error", errors.New("boom"), http.StatusInternalServerError, "function_execution_error", false},
+		{"retryable", Retryable(errors.New("timeout")), http.StatusServiceUnavailable, "function_retryable_error", true},
+		{"terminal", Terminal(errors.New("malformed")), http.StatusInternalServerError, "function_terminal_error", false},
+		{"retryable not found", Retryable(NotFound("order not found")), http.StatusNotFound, "not_found", true},
+		{"terminal unavailable", Terminal(fmt.Errorf("query: %w", ServiceUnavailable("db is down"))), http.StatusServiceUnavailable, "service_unavailable", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+				return nil, c.err
+			}
+			eventType, functionHandler := validateHandler(handler)
+			server := &functionServer{handleFunc: buildHandler(eventType, functionHandler, nil, &options{})}
+
+			rw := httptest.NewRecorder()
+			server.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rw.Code != c.status {
+				t.Errorf("status = %d, want %d", rw.Code, c.status)
+			}
+			if got := rw.Header().Get("X-Faas-Response-Error-Code"); got != c.code {
+				t.Errorf("error code = %q, want %q", got, c.code)
+			}
+
+			var fe *Error
+			if !errors.As(c.err, &fe) {
+				return
+			}
+			var body struct {
+				Status    int    `json:"status"`
+				Code      string `json:"code"`
+				Detail    string `json:"detail"`
+				Retryable bool   `json:"retryable"`
+			}
+			if err := json.Unmarshal(rw.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode body %q: %v", rw.Body.String(), err)
+			}
+			if body.Status != c.status || body.Code != c.code || body.Detail != fe.Message || body.Retryable != c.retryable {
+				t.Errorf("unexpected body %+v", body)
+			}
+		})
+	}
+}
+
+func TestRuntimeErrorBody(t *testing.T) {
+	handler := func(ctx context.Context, e *events.CloudEvent) (*events.EventResponse, error) {
+		return nil, nil
+	}
+	eventType, functionHandler := validateHandler(handler)
+	server := &functionServer{handleFunc: buildHandler(eventType, functionHandler, nil, &options{})}
+
+	rq := httptest.NewRequest(http.MethodGet, "/", nil)
+	rq.Header.Set("X-Faas-Request-Id", "req-1")
+	rw := httptest.NewRecorder()
+	server.ServeHTTP(rw, rq)
+
+	if ct := rw.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("content type = %q", ct)
+	}
+	var problem map[string]interface{}
+	if err := json.Unmarshal(rw.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode body %q: %v", rw.Body.String(), err)
+	}
+	if problem["code"] != "invalid_event_type" || problem["request_id"] != "req-1" || problem["status"] != float64(http.StatusBadRequest) {
+		t.Errorf("unexpected problem %v", problem)
+	}
+
+	plain := NewHandler(handler, WithErrorRenderer(utils.PlainTextErrorRenderer))
+
+	rw = httptest.NewRecorder()
+	plain.ServeHTTP(rw, rq)
+	if ct := rw.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("content type = %q", ct)
+	}
+	if want := "invalid_event_type: "; !strings.HasPrefix(rw.Body.String(), want) || !strings.Contains(rw.Body.String(), "req-1") {
+		t.Errorf("unexpected plain text body %q", rw.Body.String())
+	}
+
+	// The renderer is per handler, building one doesn't affect the others.
+	rw = httptest.NewRecorder()
+	server.ServeHTTP(rw, rq)
+	if ct := rw.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("content type after building another handler = %q", ct)
+	}
+}
+
+func TestRetryableTerminalHeaders(t *testing.T) {
+	cause := errors.New("boom")
+	cases := []struct {
+		name       string
+		err        error
+		retryable  string
+		retryAfter string
+	}{
+		{"plain error", cause, "", ""},
+		{"retryable", Retryable(cause), "true", ""},
+		{"retry after", Retryable(cause).WithRetryAfter(1500 * time.Millisecond), "true", "2"},
+		{"terminal", Terminal(cause), "false", ""},
+		{"terminal retryable", Terminal(ServiceUnavailable("db is down")), "false", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if !errors.Is(c.err, cause) && c.name != "terminal retryable" {
+				t.Errorf("errors.Is(%v, cause) = false", c.err)
+			}
+			handler := func(ctx context.Context, e *events.CloudEvent) (*events.EventResponse, error) {
+				return nil, c.err
+			}
+			rq, _ := events.NewBinaryRequest("http://localhost/", events.NewCloudEvent("test.event", "test", nil))
+			rw := httptest.NewRecorder()
+			newFunctionServer(handler, newOptions()).ServeHTTP(rw, rq)
+
+			if got := rw.Header().Get("X-Faas-Response-Error-Retryable"); got != c.retryable {
+				t.Errorf("retryable header = %q, want %q", got, c.retryable)
+			}
+			if got := rw.Header().Get("X-Faas-Retry-After"); got != c.retryAfter {
+				t.Errorf("retry after header = %q, want %q", got, c.retryAfter)
+			}
+		})
+	}
+}
+
+func TestRetryableTerminalBatch(t *testing.T) {
+	handler := func(ctx context.Context, e *events.CloudEvent) (*events.EventResponse, error) {
+		if e.Source() == "retry" {
+			return nil, Retryable(errors.New("timeout")).WithRetryAfter(time.Second)
+		}
+		return nil, Terminal(errors.New("malformed"))
+	}
+	rq, _ := events.NewBatchRequest("http://localhost/",
+		events.NewCloudEvent("test.event", "retry", nil), events.NewCloudEvent("test.event", "poison", nil))
+	rw := httptest.NewRecorder()
+	newFunctionServer(handler, newOptions()).ServeHTTP(rw, rq)
+
+	var report events.CloudEventBatchReport
+	if err := json.Unmarshal(rw.Body.Bytes(), &report); err != nil || len(report.Results) != 2 {
+		t.Fatalf("response = %d %s", rw.Code, rw.Body)
+	}
+	if r := report.Results[0]; !r.Retryable || r.Terminal || r.RetryAfter != 1 {
+		t.Errorf("retryable result = %+v", r)
+	}
+	if r := report.Results[1]; r.Retryable || !r.Terminal {
+		t.Errorf("terminal result = %+v", r)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+)
+
+// StartGRPC starts vefaas runtime server serving gRPC services with server over
+// h2c on the runtime port, which is typically a *grpc.Server with the services
+// registered, as it implements http.Handler:
+//
+//	s := grpc.NewServer()
+//	pb.RegisterGreeterServer(s, &greeter{})
+//	vefaas.StartGRPC(s, vefaas.WithInitializer(initializer))
+//
+// The context of gRPC handlers carries the request id and the temporary
+// credentials of the invocation, see package vefaascontext. The internal
+// endpoints, like /v1/initialize, keep working on the same port.
+func StartGRPC(server http.Handler, opts ...Option) {
+	rand.Seed(time.Now().UTC().UnixNano())
+	o := newOptions(opts...)
+	o.h2c = true
+
+	s, err := newGRPCServer(server, o)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(startServerExitCode)
+	}
+
+	// Initialize metadata.
+	loadFunctionMetadata()
+
+	serve(s, o)
+}
+
+func newGRPCServer(server http.Handler, o *options) (*functionServer, error) {
+	if server == nil {
+		return nil, errors.New("grpc server should not be nil")
+	}
+
+	s := newServer(o)
+	s.handleFunc = handleGRPC(server)
+	// The gRPC calls are always handled synchronously.
+	s.async = false
+
+	return s, nil
+}
+
+// handleGRPC serves the gRPC requests with the invocation context.
+func handleGRPC(server http.Handler) func(rw http.ResponseWriter, rq *http.Request) {
+	return func(rw http.ResponseWriter, rq *http.Request) {
+		ctx := withInvocationContext(rq.Context(), rq)
+
+		span := tracing.SpanFromContext(ctx)
+		span.SetName(strings.TrimPrefix(rq.URL.Path, "/"))
+		span.SetAttribute("rpc.system", "grpc")
+
+		server.ServeHTTP(rw, rq.WithContext(ctx))
+	}
+}
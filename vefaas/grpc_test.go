@@ -0,0 +1,101 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+)
+
+// fakeGRPCServer checks the requirements of grpc.Server.ServeHTTP, and echoes
+// the request id of the invocation in the grpc-message trailer.
+type fakeGRPCServer struct{}
+
+func (fakeGRPCServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 {
+		http.Error(rw, "gRPC requires HTTP/2", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "gRPC requires a ResponseWriter supporting http.Flusher", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/grpc")
+	rw.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	_, _ = rw.Write([]byte{0, 0, 0, 0, 0})
+	rw.Header().Set("Grpc-Status", "0")
+	rw.Header().Set("Grpc-Message", vefaascontext.RequestIdFromContext(r.Context()))
+}
+
+func TestGRPC(t *testing.T) {
+	var initialized bool
+	initializer := func(ctx context.Context) error {
+		initialized = true
+		return nil
+	}
+	server, err := newGRPCServer(fakeGRPCServer{}, newOptions(WithInitializer(initializer)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(server)
+	if err := configureH2C(ts.Config); err != nil {
+		t.Fatal(err)
+	}
+	ts.Start()
+	defer ts.Close()
+
+	transport := h2cTransport()
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	// The internal endpoints keep working.
+	rq, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/initialize", nil)
+	rq.Header.Set("X-Faas-Internal-Request", "true")
+	resp, err := client.Do(rq)
+	if err != nil {
+		t.Fatalf("initialize error = %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !initialized {
+		t.Fatalf("initialize status = %d, initialized = %v", resp.StatusCode, initialized)
+	}
+
+	rq, _ = http.NewRequest(http.MethodPost, ts.URL+"/helloworld.Greeter/SayHello", bytes.NewReader([]byte{0, 0, 0, 0, 0}))
+	rq.Header.Set("Content-Type", "application/grpc")
+	rq.Header.Set("X-Faas-Request-Id", "req-1")
+	resp, err = client.Do(rq)
+	if err != nil {
+		t.Fatalf("call error = %v", err)
+	}
+	_, _ = ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Trailer.Get("Grpc-Status") != "0" {
+		t.Fatalf("status = %d, grpc-status = %q", resp.StatusCode, resp.Trailer.Get("Grpc-Status"))
+	}
+	if id := resp.Trailer.Get("Grpc-Message"); id != "req-1" {
+		t.Errorf("request id in grpc context = %q, want %q", id, "req-1")
+	}
+}
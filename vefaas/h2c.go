@@ -0,0 +1,35 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// configureH2C enables HTTP/2 cleartext with prior knowledge on server, along
+// with HTTP/1. The HTTP/2 connections are closed gracefully on shutdown too.
+func configureH2C(server *http.Server) error {
+	h2s := &http2.Server{}
+	if err := http2.ConfigureServer(server, h2s); err != nil {
+		return err
+	}
+	server.Handler = h2c.NewHandler(server.Handler, h2s)
+
+	return nil
+}
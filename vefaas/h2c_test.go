@@ -0,0 +1,89 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"golang.org/x/net/http2"
+)
+
+// h2cTransport speaks HTTP/2 cleartext with prior knowledge.
+func h2cTransport() *http2.Transport {
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}
+}
+
+func TestH2C(t *testing.T) {
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		return &events.EventResponse{StatusCode: http.StatusOK, Body: []byte(r.Headers["X-Proto"])}, nil
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		r.Header.Set("X-Proto", r.Proto)
+		newFunctionServer(handler, newOptions()).ServeHTTP(rw, r)
+	}))
+	if err := configureH2C(ts.Config); err != nil {
+		t.Fatal(err)
+	}
+	ts.Start()
+	defer ts.Close()
+
+	transport := h2cTransport()
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	// Concurrent invocations are multiplexed over the h2c connection.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(ts.URL)
+			if err != nil {
+				t.Errorf("Get() error = %v", err)
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			if resp.ProtoMajor != 2 || string(body) != "HTTP/2.0" {
+				t.Errorf("response proto = %s, handler saw %q, want HTTP/2.0", resp.Proto, body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// HTTP/1 keeps working.
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.ProtoMajor != 1 {
+		t.Errorf("response proto = %s, want HTTP/1.1", resp.Proto)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"reflect"
 
 	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/websocket"
 )
 
 var (
@@ -32,6 +33,8 @@ var (
 	httpRequestType   = reflect.TypeOf(&events.HTTPRequest{})
 	eventResponseType = reflect.TypeOf(&events.EventResponse{})
 	cloudEventType    = reflect.TypeOf(&events.CloudEvent{})
+	cloudEventsType   = reflect.TypeOf([]*events.CloudEvent{})
+	webSocketConnType = reflect.TypeOf(&websocket.Conn{})
 )
 
 type (
@@ -42,10 +45,22 @@ type (
 	// tos event, kafka event, etc.
 	cloudeventFunctionHandler = func(context.Context, *events.CloudEvent) (*events.EventResponse, error)
 
+	// cloudeventReplyFunctionHandler handles CloudEvent request and replies with
+	// a CloudEvent, it's adapted to cloudeventFunctionHandler.
+	cloudeventReplyFunctionHandler = func(context.Context, *events.CloudEvent) (*events.CloudEvent, error)
+
+	// cloudeventBatchFunctionHandler handles batches of CloudEvents, as well as
+	// single CloudEvents as batches of one.
+	cloudeventBatchFunctionHandler = func(context.Context, []*events.CloudEvent) (*events.EventResponse, error)
+
 	// anyFunctionHandler handles requests of any type, it might be used when
 	// you want to handle both regular http request and other event trigger requests
 	// like timer, tos, kafka, etc.
 	anyFunctionHandler = func(context.Context, interface{}) (*events.EventResponse, error)
+
+	// webSocketFunctionHandler handles a WebSocket connection upgraded from the
+	// http request of HTTP triggers.
+	webSocketFunctionHandler = func(context.Context, *websocket.Conn) error
 )
 
 // validateHandler validates and creates the base function handler, which will do
@@ -92,6 +107,9 @@ func validateHandler(handlerSymbol interface{}) (eventType string, functionHandl
 	}
 
 	functionHandler = handlerSymbol
+	if h, ok := handlerSymbol.(cloudeventReplyFunctionHandler); ok {
+		functionHandler = replyCloudEventHandler(h)
+	}
 
 	return
 }
@@ -118,18 +136,35 @@ func validateHandlerArguments(handler reflect.Type) (eventType string, err error
 		eventType = events.EventTypeCloudEvent
 		return
 	}
+	if handler.In(1) == cloudEventsType {
+		eventType = events.EventTypeCloudEventBatch
+		return
+	}
+	if handler.In(1) == webSocketConnType {
+		eventType = events.EventTypeWebSocket
+		return
+	}
 
 	err = fmt.Errorf("the second argument of handler should be one of "+
-		"(*events.HTTPRequest, *events.CloudEvent, interface{}), but got %s", handler.In(1))
+		"(*events.HTTPRequest, *events.CloudEvent, []*events.CloudEvent, *websocket.Conn, interface{}), but got %s", handler.In(1))
 	return
 }
 
 func validateHandlerReturnValues(handler reflect.Type, eventType string) error {
+	if eventType == events.EventTypeWebSocket {
+		if handler.NumOut() != 1 || !handler.Out(0).Implements(errorType) {
+			return fmt.Errorf("websocket handler should return one value (error), but got %d", handler.NumOut())
+		}
+		return nil
+	}
 	if handler.NumOut() != 2 {
 		return fmt.Errorf("handler should return two values, but got %d", handler.NumOut())
 	}
-	if handler.Out(0) != eventResponseType {
-		return fmt.Errorf("the first return value of handler should be events.EventResponse, but got %s", handler.Out(0))
+	// CloudEvent handlers may reply with a CloudEvent.
+	reply := eventType == events.EventTypeCloudEvent && handler.Out(0) == cloudEventType
+	if handler.Out(0) != eventResponseType && !reply {
+		return fmt.Errorf("the first return value of handler should be events.EventResponse, "+
+			"or events.CloudEvent for CloudEvent handlers, but got %s", handler.Out(0))
 	}
 	if !handler.Out(1).Implements(errorType) {
 		return fmt.Errorf("the second return value of handler should implement error, but got %s", handler.Out(1))
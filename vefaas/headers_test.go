@@ -0,0 +1,62 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+)
+
+func TestPlatformHeaders(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		var headers map[string]string
+		var creds vefaascontext.Credentials
+		handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+			headers, creds = r.Headers, vefaascontext.CredentialsFromContext(ctx)
+			return &events.EventResponse{}, nil
+		}
+		server := newFunctionServer(handler, newOptions(WithPlatformHeaders(keep)))
+
+		rq := httptest.NewRequest(http.MethodGet, "/", nil)
+		rq.Header.Set("X-Faas-Access-Key-Id", "AKLTtest")
+		rq.Header.Set("X-Faas-Secret-Access-Key", "secret")
+		rq.Header.Set("X-Faas-Session-Token", "token")
+		rq.Header.Set("X-Faas-Request-Id", "req-1")
+		rq.Header.Set("Authorization", "Bearer user-token")
+		server.ServeHTTP(httptest.NewRecorder(), rq)
+
+		if _, ok := headers["X-Faas-Secret-Access-Key"]; ok != keep {
+			t.Errorf("keep = %v, X-Faas-Secret-Access-Key present = %v", keep, ok)
+		}
+		if _, ok := headers["X-Faas-Session-Token"]; ok != keep {
+			t.Errorf("keep = %v, X-Faas-Session-Token present = %v", keep, ok)
+		}
+		if headers["X-Faas-Request-Id"] != "req-1" {
+			t.Errorf("keep = %v, X-Faas-Request-Id = %q, want kept", keep, headers["X-Faas-Request-Id"])
+		}
+		if headers["Authorization"] != "Bearer user-token" {
+			t.Errorf("keep = %v, Authorization = %q, want kept", keep, headers["Authorization"])
+		}
+		if creds.SecretAccessKey != "secret" || creds.SessionToken != "token" {
+			t.Errorf("keep = %v, credentials in context = %+v", keep, creds)
+		}
+	}
+}
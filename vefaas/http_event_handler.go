@@ -23,13 +23,12 @@ import (
 
 	"github.com/volcengine/vefaas-golang-runtime/events"
 	"github.com/volcengine/vefaas-golang-runtime/utils"
-	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
 )
 
-func handleHttpEvent(handler interface{}) func(rw http.ResponseWriter, rq *http.Request) {
+func handleHttpEvent(handler interface{}, o *options) func(rw http.ResponseWriter, rq *http.Request) {
 	functionHandler := handler.(httpFunctionHandler)
 	return func(rw http.ResponseWriter, rq *http.Request) {
-		defer utils.RecoverFunc(rw, nil)
+		defer recoverFunction(rq.Context(), rw)
 
 		eventType := rq.Header.Get("X-Faas-Event-Type")
 		if eventType != "" && eventType != events.EventTypeHTTP {
@@ -37,11 +36,7 @@ func handleHttpEvent(handler interface{}) func(rw http.ResponseWriter, rq *http.
 			return
 		}
 
-		ctx := rq.Context()
-		ctx = vefaascontext.WithRequestIdContext(ctx, rq)
-		ctx = vefaascontext.WithAccessKeyIdContext(ctx, rq)
-		ctx = vefaascontext.WithSecretAccessKeyContext(ctx, rq)
-		ctx = vefaascontext.WithSessionTokenContext(ctx, rq)
+		ctx := withInvocationContext(rq.Context(), rq)
 
 		rawBody, err := utils.RawBodyFromHttpRequest(rq)
 		if err != nil {
@@ -65,33 +60,12 @@ func handleHttpEvent(handler interface{}) func(rw http.ResponseWriter, rq *http.
 			Body:                  rawBody,
 		}
 		utils.SetHttpParamsAndHeaders(req, rq)
+		if !o.keepPlatformHeaders {
+			utils.StripPlatformHeaders(req.Headers)
+		}
 
 		startTime := time.Now()
 		resp, err := functionHandler(ctx, req)
-		utils.SetExecutionDurationHeader(rw, startTime)
-		if err != nil {
-			utils.SetFunctionExecutionErrorHeader(rw, err)
-			return
-		}
-
-		if resp == nil {
-			utils.SetFunctionNoResponseErrorHeader(rw)
-			return
-		}
-
-		if resp.Headers != nil {
-			for k, v := range resp.Headers {
-				rw.Header().Set(k, v)
-			}
-		}
-		// In case user set this response header, we need to rewrite it here.
-		utils.SetExecutionDurationHeader(rw, startTime)
-
-		if resp.StatusCode != 0 {
-			rw.WriteHeader(resp.StatusCode)
-		}
-		if resp.Body != nil {
-			_, _ = rw.Write(resp.Body)
-		}
+		writeEventResponse(ctx, rw, startTime, resp, err)
 	}
 }
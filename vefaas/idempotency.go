@@ -0,0 +1,195 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/idempotency"
+)
+
+// DefaultIdempotencyTTL is how long the responses are kept for the duplicate
+// invocations by default, see WithIdempotency.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+const idempotentReplayedHeader = "X-Faas-Idempotent-Replayed"
+
+// idempotentHandler wraps handler to suppress the duplicate invocations, if
+// enabled by WithIdempotency.
+func idempotentHandler(eventType string, handler interface{}, o *options) interface{} {
+	if o.idempotencyStore == nil {
+		return handler
+	}
+	g := &idempotencyGuard{store: o.idempotencyStore, ttl: o.idempotencyTTL}
+	if g.ttl <= 0 {
+		g.ttl = DefaultIdempotencyTTL
+	}
+
+	switch eventType {
+	case events.EventTypeHTTP:
+		h := handler.(httpFunctionHandler)
+		return httpFunctionHandler(func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+			return g.do(ctx, httpIdempotencyKey(r), httpFingerprint(r), func(ctx context.Context) (*events.EventResponse, error) { return h(ctx, r) })
+		})
+	case events.EventTypeCloudEvent:
+		h := handler.(cloudeventFunctionHandler)
+		return cloudeventFunctionHandler(func(ctx context.Context, e *events.CloudEvent) (*events.EventResponse, error) {
+			return g.do(ctx, cloudEventIdempotencyKey(e), "", func(ctx context.Context) (*events.EventResponse, error) { return h(ctx, e) })
+		})
+	case events.EventTypeAny:
+		h := handler.(anyFunctionHandler)
+		return anyFunctionHandler(func(ctx context.Context, payload interface{}) (*events.EventResponse, error) {
+			var key, fingerprint string
+			switch p := payload.(type) {
+			case *events.HTTPRequest:
+				key, fingerprint = httpIdempotencyKey(p), httpFingerprint(p)
+			case *events.CloudEvent:
+				key = cloudEventIdempotencyKey(p)
+			}
+			return g.do(ctx, key, fingerprint, func(ctx context.Context) (*events.EventResponse, error) { return h(ctx, payload) })
+		})
+	default:
+		return handler
+	}
+}
+
+// idempotencyGuard runs the invocations at most once per key.
+type idempotencyGuard struct {
+	store idempotency.Store
+	ttl   time.Duration
+}
+
+// do runs invoke once per key, a duplicate invocation with another fingerprint,
+// if both known, is rejected with 422 instead of replaying the response.
+func (g *idempotencyGuard) do(ctx context.Context, key, fingerprint string, invoke func(context.Context) (*events.EventResponse, error)) (*events.EventResponse, error) {
+	if key == "" {
+		return invoke(ctx)
+	}
+
+	// The key is held no longer than the function timeout, in case the
+	// invocation never completes, like the instance crashed.
+	record, acquired, err := g.store.Acquire(ctx, key, fingerprint, time.Duration(requestTimeoutSecond)*time.Second)
+	if errors.Is(err, idempotency.ErrStoreFull) {
+		return nil, ServiceUnavailable("too many invocations in progress")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire idempotency key, %w", err)
+	}
+	if !acquired {
+		if fingerprint != "" && record.Fingerprint != "" && fingerprint != record.Fingerprint {
+			return nil, NewError(http.StatusUnprocessableEntity, "idempotency_key_reused",
+				"idempotency key is reused by a different request")
+		}
+		if record.InProgress() {
+			return nil, Conflict("invocation with the same idempotency key is in progress").WithRetryable(true)
+		}
+		return replayedResponse(record.Response), nil
+	}
+
+	// The records are updated even if the invocation timed out.
+	completed := false
+	defer func() {
+		if !completed {
+			_ = g.store.Release(context.Background(), key)
+		}
+	}()
+
+	marker := &batchItemFailureMarker{}
+	resp, err := invoke(context.WithValue(ctx, batchItemFailureMarkerKey{}, marker))
+	if err != nil || resp == nil || resp.BodyStream != nil || resp.StatusCode >= http.StatusInternalServerError || marker.failed {
+		return resp, err
+	}
+	if err := g.store.Complete(context.Background(), key, resp, g.ttl); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to store idempotency record, %v.\n", err)
+		return resp, nil
+	}
+	completed = true
+
+	return resp, nil
+}
+
+type batchItemFailureMarkerKey struct{}
+
+// batchItemFailureMarker is set by KafkaMessageHandler and RocketMqMessageHandler
+// in the invocation context once they report failed messages, which are
+// redelivered and must be processed again rather than replayed.
+type batchItemFailureMarker struct {
+	failed bool
+}
+
+// markBatchItemFailures marks the failed messages reported in ctx, if guarded.
+func markBatchItemFailures(ctx context.Context) {
+	if marker, ok := ctx.Value(batchItemFailureMarkerKey{}).(*batchItemFailureMarker); ok {
+		marker.failed = true
+	}
+}
+
+// replayedResponse copies resp for a duplicate invocation.
+func replayedResponse(resp *events.EventResponse) *events.EventResponse {
+	replayed := &events.EventResponse{
+		StatusCode: resp.StatusCode,
+		Headers:    make(map[string]string, len(resp.Headers)+1),
+		Body:       resp.Body,
+	}
+	for k, v := range resp.Headers {
+		replayed.Headers[k] = v
+	}
+	replayed.Headers[idempotentReplayedHeader] = "true"
+
+	return replayed
+}
+
+// httpIdempotencyKey returns the key of r from the Idempotency-Key header, or
+// X-Idempotency-Key, scoped to the method and path of r.
+func httpIdempotencyKey(r *events.HTTPRequest) string {
+	if r == nil {
+		return ""
+	}
+	for k, v := range r.Headers {
+		if v != "" && (strings.EqualFold(k, "Idempotency-Key") || strings.EqualFold(k, "X-Idempotency-Key")) {
+			return "http:" + r.HTTPMethod + " " + r.Path + ":" + v
+		}
+	}
+	return ""
+}
+
+// httpFingerprint returns the digest of the body of r, which tells whether an
+// idempotency key is reused by a different request.
+func httpFingerprint(r *events.HTTPRequest) string {
+	if r == nil {
+		return ""
+	}
+	sum := sha256.Sum256(r.Body)
+	return hex.EncodeToString(sum[:])
+}
+
+// cloudEventIdempotencyKey returns the key of e, which is identified by its id
+// and source according to the CloudEvents specification.
+func cloudEventIdempotencyKey(e *events.CloudEvent) string {
+	if e == nil || e.Event == nil || e.ID() == "" {
+		return ""
+	}
+	return "cloudevent:" + e.Source() + ":" + e.ID()
+}
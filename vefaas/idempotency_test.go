@@ -0,0 +1,219 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/idempotency"
+)
+
+func TestIdempotencyHTTP(t *testing.T) {
+	var calls int32
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if string(r.Body) == "fail" && n == 1 {
+			return nil, errors.New("boom")
+		}
+		return &events.EventResponse{StatusCode: http.StatusCreated, Body: r.Body}, nil
+	}
+	s := newFunctionServer(handler, newOptions(WithIdempotency(idempotency.NewMemoryStore(0), time.Minute)))
+
+	invoke := func(path, key, body string) *httptest.ResponseRecorder {
+		rq := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rq.Header.Set("X-Faas-Event-Type", events.EventTypeHTTP)
+		if key != "" {
+			rq.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, rq)
+		return rec
+	}
+
+	first := invoke("/orders", "k1", "order")
+	second := invoke("/orders", "k1", "order")
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated || second.Body.String() != "order" {
+		t.Errorf("responses = %d %s, %d %s", first.Code, first.Body, second.Code, second.Body)
+	}
+	if first.Header().Get("X-Faas-Idempotent-Replayed") != "" || second.Header().Get("X-Faas-Idempotent-Replayed") != "true" {
+		t.Errorf("replayed headers = %q, %q", first.Header().Get("X-Faas-Idempotent-Replayed"), second.Header().Get("X-Faas-Idempotent-Replayed"))
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("handler called %d times, want 1", n)
+	}
+
+	// The key reused by a different request is rejected.
+	if rec := invoke("/orders", "k1", "other"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("response with another body = %d %s, want 422", rec.Code, rec.Body)
+	}
+	// The keys are scoped to the method and path.
+	if rec := invoke("/refunds", "k1", "refund"); rec.Code != http.StatusCreated || rec.Body.String() != "refund" {
+		t.Errorf("response on another path = %d %s", rec.Code, rec.Body)
+	}
+
+	// Requests without a key are not deduplicated.
+	invoke("/orders", "", "a")
+	invoke("/orders", "", "a")
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Errorf("handler called %d times, want 4", n)
+	}
+}
+
+func TestIdempotencyErrorReleased(t *testing.T) {
+	var calls int32
+	handler := func(ctx context.Context, e *events.CloudEvent) (*events.EventResponse, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil, errors.New("boom")
+		}
+		return &events.EventResponse{}, nil
+	}
+	s := newFunctionServer(handler, newOptions(WithIdempotency(idempotency.NewMemoryStore(0), time.Minute)))
+	event := events.NewCloudEvent("test.event", "test", nil)
+
+	for i, want := range []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK} {
+		rq, _ := events.NewBinaryRequest("http://localhost/", event)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, rq)
+		if rec.Code != want {
+			t.Errorf("invocation %d: status = %d, want %d", i, rec.Code, want)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("handler called %d times, want 2", n)
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := func(ctx context.Context, e *events.CloudEvent) (*events.EventResponse, error) {
+		close(started)
+		<-release
+		return &events.EventResponse{}, nil
+	}
+	s := newFunctionServer(handler, newOptions(WithIdempotency(idempotency.NewMemoryStore(0), time.Minute)))
+	event := events.NewCloudEvent("test.event", "test", nil)
+
+	done := make(chan int)
+	go func() {
+		rq, _ := events.NewBinaryRequest("http://localhost/", event)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, rq)
+		done <- rec.Code
+	}()
+	<-started
+
+	rq, _ := events.NewBinaryRequest("http://localhost/", event)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, rq)
+	if rec.Code != http.StatusConflict || rec.Header().Get("X-Faas-Response-Error-Code") != "conflict" {
+		t.Errorf("duplicate response = %d %v", rec.Code, rec.Header())
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("first response = %d", code)
+	}
+}
+
+func TestIdempotencyBatch(t *testing.T) {
+	var calls int32
+	handler := func(ctx context.Context, e *events.CloudEvent) (*events.EventResponse, error) {
+		atomic.AddInt32(&calls, 1)
+		return &events.EventResponse{}, nil
+	}
+	s := newFunctionServer(handler, newOptions(WithIdempotency(idempotency.NewMemoryStore(0), time.Minute)))
+	event := events.NewCloudEvent("test.event", "test", nil)
+
+	rq, _ := events.NewBatchRequest("http://localhost/", event, event, events.NewCloudEvent("test.event", "test", nil))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, rq)
+	var report events.CloudEventBatchReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("response = %d %s", rec.Code, rec.Body)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("handler called %d times, want 2", n)
+	}
+}
+
+func TestIdempotencyBatchItemFailures(t *testing.T) {
+	var calls int32
+	handler := KafkaMessageHandler(func(ctx context.Context, m *events.KafkaMessage) error {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	s := newFunctionServer(handler, newOptions(WithIdempotency(idempotency.NewMemoryStore(0), time.Minute)))
+	event := events.NewKafkaEvent("orders", []byte("a"), []byte("b"))
+
+	deliver := func() *httptest.ResponseRecorder {
+		rq, _ := events.NewBinaryRequest("http://localhost/", event)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, rq)
+		return rec
+	}
+
+	// The report of the failed message is not replayed to the redelivery.
+	if rec := deliver(); rec.Code != http.StatusMultiStatus {
+		t.Fatalf("first delivery = %d %s, want 207", rec.Code, rec.Body)
+	}
+	if rec := deliver(); rec.Code != http.StatusOK || rec.Header().Get("X-Faas-Idempotent-Replayed") != "" {
+		t.Fatalf("redelivery = %d %v, want processed again", rec.Code, rec.Header())
+	}
+	if rec := deliver(); rec.Code != http.StatusOK || rec.Header().Get("X-Faas-Idempotent-Replayed") != "true" {
+		t.Errorf("duplicate = %d %v, want replayed", rec.Code, rec.Header())
+	}
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Errorf("handler called %d times, want 4", n)
+	}
+}
+
+func TestIdempotencyMultiStatusReplayed(t *testing.T) {
+	var calls int32
+	handler := func(ctx context.Context, e *events.CloudEvent) (*events.EventResponse, error) {
+		atomic.AddInt32(&calls, 1)
+		// Only the failures reported by the message handlers are retried.
+		return &events.EventResponse{
+			StatusCode: http.StatusMultiStatus,
+			Body:       []byte(`{"batch_item_failures":[{"topic":"orders"}]}`),
+		}, nil
+	}
+	s := newFunctionServer(handler, newOptions(WithIdempotency(idempotency.NewMemoryStore(0), time.Minute)))
+	event := events.NewCloudEvent("test.event", "test", nil)
+
+	for i := 0; i < 2; i++ {
+		rq, _ := events.NewBinaryRequest("http://localhost/", event)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, rq)
+		if rec.Code != http.StatusMultiStatus {
+			t.Fatalf("delivery %d = %d %s, want 207", i, rec.Code, rec.Body)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("handler called %d times, want 1", n)
+	}
+}
@@ -24,8 +24,6 @@ import (
 	"runtime/debug"
 )
 
-var functionInitialized bool
-
 type initializer = func(context.Context) error
 
 // validateInitializer validates and creates function initializer, which is in charge
@@ -96,14 +94,14 @@ func initializerErrorFunc(err error) initializer {
 	}
 }
 
-func initializeFunction(ctx context.Context, initializerFunc interface{}) (err error) {
+func initializeFunction(ctx context.Context, initializerFunc interface{}, initialized *bool) (err error) {
 	// No initializer provided.
 	if initializerFunc == nil {
 		return
 	}
 
 	// Return directly if the initializer has been executed successfully.
-	if functionInitialized {
+	if *initialized {
 		return
 	}
 
@@ -123,7 +121,7 @@ func initializeFunction(ctx context.Context, initializerFunc interface{}) (err e
 	}
 
 	// Set function as initialized.
-	functionInitialized = true
+	*initialized = true
 
 	return
 }
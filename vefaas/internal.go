@@ -0,0 +1,116 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+	"github.com/volcengine/vefaas-golang-runtime/version"
+)
+
+const (
+	internalRequestHeader = "X-Faas-Internal-Request"
+	internalTokenHeader   = "X-Faas-Internal-Token"
+)
+
+// credentialHeaders carry the temporary credentials handed out by the platform.
+var credentialHeaders = []string{
+	"X-Faas-Access-Key-Id",
+	"X-Faas-Secret-Access-Key",
+	"X-Faas-Session-Token",
+	"X-Faas-Credentials-Expiration",
+}
+
+// isInternalRequest reports whether r is a control call of the platform, like
+// /v1/initialize. The control calls are only served on the admin listener if
+// there is one, otherwise besides the X-Faas-Internal-Request header, r must
+//   - not be forwarded by the trigger gateway, which sets X-Real-Ip,
+//   - carry the internal token in X-Faas-Internal-Token, if configured,
+//   - come from loopback, if restricted to.
+func (s *functionServer) isInternalRequest(r *http.Request) bool {
+	if s.internalOnAdmin || r.Header.Get(internalRequestHeader) != "true" {
+		return false
+	}
+	if r.Header.Get("X-Real-Ip") != "" {
+		return false
+	}
+	if s.internalToken != "" {
+		token := r.Header.Get(internalTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.internalToken)) != 1 {
+			return false
+		}
+	}
+	if s.internalLoopbackOnly && !isLocal(r) {
+		return false
+	}
+
+	return true
+}
+
+// isLocal reports whether r comes from the local host, either from loopback, or
+// over a unix socket, whose peers have no address.
+func isLocal(r *http.Request) bool {
+	if _, ok := r.Context().Value(http.LocalAddrContextKey).(*net.UnixAddr); ok {
+		return true
+	}
+	return isLoopback(r.RemoteAddr)
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveInternal serves the control calls of the platform.
+func (s *functionServer) serveInternal(rw http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/initialize":
+		switch r.Method {
+		case http.MethodPost:
+			ctx := withInvocationContext(context.Background(), r)
+			// The credentials of the control calls are handed out by the
+			// platform, so they are trusted by the clients outliving invocations.
+			vefaascontext.RecordLatestCredentials(vefaascontext.CredentialsFromContext(ctx))
+			err := s.initialize(ctx)
+			if err != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+			} else {
+				rw.WriteHeader(http.StatusOK)
+			}
+		default:
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	case "/v1/version":
+		switch r.Method {
+		case http.MethodGet:
+			rw.WriteHeader(http.StatusOK)
+			_, _ = rw.Write([]byte(version.Version))
+		default:
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	default:
+		rw.WriteHeader(http.StatusNotFound)
+	}
+}
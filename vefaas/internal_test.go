@@ -0,0 +1,170 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+	"github.com/volcengine/vefaas-golang-runtime/version"
+)
+
+func TestInternalRequestSpoofing(t *testing.T) {
+	cases := []struct {
+		name       string
+		opts       []Option
+		remoteAddr string
+		headers    map[string]string
+		internal   bool
+	}{
+		{"platform", nil, "127.0.0.1:1234", nil, true},
+		{"forwarded by gateway", nil, "127.0.0.1:1234", map[string]string{"X-Real-Ip": "203.0.113.7"}, false},
+		{"not true", nil, "127.0.0.1:1234", map[string]string{"X-Faas-Internal-Request": "1"}, false},
+		{"remote without token", nil, "10.0.0.1:1234", nil, true},
+		{"token", []Option{WithInternalToken("t0ken")}, "10.0.0.1:1234", map[string]string{"X-Faas-Internal-Token": "t0ken"}, true},
+		{"missing token", []Option{WithInternalToken("t0ken")}, "10.0.0.1:1234", nil, false},
+		{"wrong token", []Option{WithInternalToken("t0ken")}, "10.0.0.1:1234", map[string]string{"X-Faas-Internal-Token": "guess"}, false},
+		{"loopback", []Option{WithInternalToken("t0ken"), WithInternalLoopbackOnly(true)}, "[::1]:1234", map[string]string{"X-Faas-Internal-Token": "t0ken"}, true},
+		{"not loopback", []Option{WithInternalToken("t0ken"), WithInternalLoopbackOnly(true)}, "203.0.113.7:1234", map[string]string{"X-Faas-Internal-Token": "t0ken"}, false},
+		{"loopback without token", []Option{WithInternalLoopbackOnly(true)}, "127.0.0.1:1234", nil, true},
+		{"not loopback without token", []Option{WithInternalLoopbackOnly(true)}, "10.0.0.1:1234", nil, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var invoked bool
+			var headers map[string]string
+			handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+				invoked, headers = true, r.Headers
+				return &events.EventResponse{StatusCode: http.StatusTeapot}, nil
+			}
+			server := newFunctionServer(handler, newOptions(append(c.opts, WithPlatformHeaders(true))...))
+
+			rq := httptest.NewRequest(http.MethodGet, "/v1/version", nil)
+			rq.RemoteAddr = c.remoteAddr
+			rq.Header.Set("X-Faas-Internal-Request", "true")
+			for k, v := range c.headers {
+				rq.Header.Set(k, v)
+			}
+			rw := httptest.NewRecorder()
+			server.ServeHTTP(rw, rq)
+
+			if c.internal {
+				if invoked || rw.Code != http.StatusOK || rw.Body.String() != version.Version {
+					t.Errorf("want internal response, got status %d, body %q, invoked %v", rw.Code, rw.Body.String(), invoked)
+				}
+				return
+			}
+			if !invoked || rw.Code != http.StatusTeapot {
+				t.Fatalf("want function invoked, got status %d, invoked %v", rw.Code, invoked)
+			}
+			for _, h := range []string{"X-Faas-Internal-Request", "X-Faas-Internal-Token"} {
+				if _, ok := headers[h]; ok {
+					t.Errorf("%s is not stripped from user traffic", h)
+				}
+			}
+		})
+	}
+}
+
+func TestInternalRequestCredentials(t *testing.T) {
+	var creds vefaascontext.Credentials
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		creds = vefaascontext.CredentialsFromContext(ctx)
+		return &events.EventResponse{}, nil
+	}
+	server := newFunctionServer(handler, newOptions(WithInternalToken("t0ken")))
+
+	invoke := func(path, ak, token string) {
+		rq := httptest.NewRequest(http.MethodPost, path, nil)
+		rq.Header.Set("X-Faas-Internal-Request", "true")
+		rq.Header.Set("X-Faas-Internal-Token", token)
+		rq.Header.Set("X-Faas-Access-Key-Id", ak)
+		rq.Header.Set("X-Faas-Secret-Access-Key", "sk")
+		server.ServeHTTP(httptest.NewRecorder(), rq)
+	}
+
+	invoke("/v1/initialize", "platform-ak", "t0ken")
+	if got := vefaascontext.LatestCredentials().AccessKeyId; got != "platform-ak" {
+		t.Errorf("latest credentials = %q, want recorded from the platform", got)
+	}
+
+	// The credentials of spoofed internal requests are dropped.
+	invoke("/", "spoofed-ak", "guess")
+	if creds.IsValid() || vefaascontext.LatestCredentials().AccessKeyId != "platform-ak" {
+		t.Errorf("spoofed credentials used: %+v, latest %+v", creds, vefaascontext.LatestCredentials())
+	}
+
+	// Nor are the credentials of invocations recorded for the process.
+	rq := httptest.NewRequest(http.MethodPost, "/", nil)
+	rq.Header.Set("X-Faas-Access-Key-Id", "caller-ak")
+	rq.Header.Set("X-Faas-Secret-Access-Key", "sk")
+	server.ServeHTTP(httptest.NewRecorder(), rq)
+	if creds.AccessKeyId != "caller-ak" || vefaascontext.LatestCredentials().AccessKeyId != "platform-ak" {
+		t.Errorf("invocation credentials %+v, latest %+v", creds, vefaascontext.LatestCredentials())
+	}
+}
+
+func TestInternalRequestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vefaas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "function.sock")
+
+	initialized := false
+	initializer := func(ctx context.Context) error {
+		initialized = true
+		return nil
+	}
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		return &events.EventResponse{StatusCode: http.StatusTeapot}, nil
+	}
+	server := newFunctionServer(handler, newOptions(WithInitializer(initializer), WithInternalLoopbackOnly(true)))
+
+	l, err := listen(unixAddrPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := &http.Server{Handler: server}
+	go func() { _ = httpServer.Serve(l) }()
+	defer httpServer.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+	rq, _ := http.NewRequest(http.MethodPost, "http://vefaas/v1/initialize", nil)
+	rq.Header.Set("X-Faas-Internal-Request", "true")
+	resp, err := client.Do(rq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !initialized {
+		t.Errorf("initialize over unix socket: status %d, initialized %v", resp.StatusCode, initialized)
+	}
+}
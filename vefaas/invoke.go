@@ -0,0 +1,190 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+const (
+	invokeFlag     = "invoke"
+	invokeEventEnv = "VEFAAS_INVOKE_EVENT"
+
+	invokeErrorExitCode = 1
+	invokeFlushTimeout  = 5 * time.Second
+)
+
+// invokeHTTPRequest is the http request described in an invoke event file.
+type invokeHTTPRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   map[string]string `json:"query"`
+	Headers map[string]string `json:"headers"`
+
+	// Body is sent as is if it's a json string, otherwise the json value.
+	Body json.RawMessage `json:"body"`
+}
+
+// invokeEventSource returns the invoke event given by the --invoke command line
+// flag, or the VEFAAS_INVOKE_EVENT environment variable. The command line is
+// scanned rather than parsed with package flag, which is left to the function.
+func invokeEventSource(args []string) (string, bool) {
+	for i, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name == arg || len(arg)-len(name) > 2 {
+			continue
+		}
+		if name == invokeFlag && i+1 < len(args) {
+			return args[i+1], true
+		}
+		if strings.HasPrefix(name, invokeFlag+"=") {
+			return strings.TrimPrefix(name, invokeFlag+"="), true
+		}
+	}
+
+	s := os.Getenv(invokeEventEnv)
+	return s, s != ""
+}
+
+// invokeOnce initializes the function and invokes it once with the event read
+// from source, either a file path or inline json, then prints the response to
+// out. It returns the process exit code, which is non-zero if either the
+// initialization or the invocation failed.
+func invokeOnce(s *functionServer, source string, out io.Writer) int {
+	rq, err := newInvokeRequest(source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid invoke event, %v.\n", err)
+		return invokeErrorExitCode
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(requestTimeoutSecond)*time.Second)
+	defer cancel()
+	// Flush the spans, including the ones of a failed initialization.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), invokeFlushTimeout)
+		defer cancel()
+		_ = s.tracer.Shutdown(ctx)
+	}()
+
+	if err := s.initialize(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to invoke function, %v.\n", err)
+		return invokeErrorExitCode
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, rq.WithContext(ctx))
+
+	result := rec.Result()
+	fmt.Fprintf(out, "%s %s\n", result.Proto, result.Status)
+	keys := make([]string, 0, len(result.Header))
+	for k := range result.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(out, "%s: %s\n", k, strings.Join(result.Header[k], ","))
+	}
+	fmt.Fprintf(out, "\n%s\n", rec.Body.Bytes())
+
+	if result.Header.Get("X-Faas-Response-Error-Code") != "" || result.StatusCode >= http.StatusBadRequest {
+		return invokeErrorExitCode
+	}
+	return 0
+}
+
+// newInvokeRequest creates the invocation request from the event in source,
+// which is a structured mode CloudEvent if it has specversion, otherwise an
+// http request like:
+//
+//	{"method": "POST", "path": "/orders", "query": {}, "headers": {}, "body": {}}
+func newInvokeRequest(source string) (*http.Request, error) {
+	raw := []byte(source)
+	if !strings.HasPrefix(strings.TrimSpace(source), "{") {
+		var err error
+		if raw, err = ioutil.ReadFile(source); err != nil {
+			return nil, err
+		}
+	}
+
+	var probe struct {
+		SpecVersion string `json:"specversion"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, err
+	}
+
+	var rq *http.Request
+	if probe.SpecVersion != "" {
+		event := cloudevents.NewEvent()
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return nil, err
+		}
+		var err error
+		if rq, err = events.NewBinaryRequest("http://localhost/", &events.CloudEvent{Event: &event}); err != nil {
+			return nil, err
+		}
+	} else {
+		var desc invokeHTTPRequest
+		if err := json.Unmarshal(raw, &desc); err != nil {
+			return nil, err
+		}
+		if desc.Method == "" {
+			desc.Method = http.MethodGet
+		}
+		if desc.Path == "" {
+			desc.Path = "/"
+		}
+
+		r := events.NewHTTPRequest(desc.Method, desc.Path, nil)
+		for k, v := range desc.Query {
+			r.QueryStringParameters[k] = v
+		}
+		for k, v := range desc.Headers {
+			r.Headers[k] = v
+		}
+		var text string
+		if err := json.Unmarshal(desc.Body, &text); err == nil {
+			r.Body = []byte(text)
+		} else if len(bytes.TrimSpace(desc.Body)) > 0 {
+			r.Body = desc.Body
+		}
+
+		var err error
+		if rq, err = r.NewRequest("http://localhost/"); err != nil {
+			return nil, err
+		}
+	}
+
+	rq.RemoteAddr = "127.0.0.1:0"
+	rq.Header.Set("X-Faas-Request-Id", fmt.Sprintf("invoke-%d", time.Now().UnixNano()))
+
+	return rq, nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaas
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+)
+
+func TestInvokeEventSource(t *testing.T) {
+	cases := []struct {
+		args   []string
+		source string
+		ok     bool
+	}{
+		{[]string{"--invoke", "event.json"}, "event.json", true},
+		{[]string{"-v", "-invoke=event.json"}, "event.json", true},
+		{[]string{"invoke", "event.json"}, "", false},
+		{[]string{"---invoke", "event.json"}, "", false},
+		{[]string{"--invoke"}, "", false},
+	}
+	for _, c := range cases {
+		source, ok := invokeEventSource(c.args)
+		if source != c.source || ok != c.ok {
+			t.Errorf("invokeEventSource(%v) = %q, %v, want %q, %v", c.args, source, ok, c.source, c.ok)
+		}
+	}
+}
+
+func TestInvokeOnce(t *testing.T) {
+	handler := func(ctx context.Context, payload interface{}) (*events.EventResponse, error) {
+		switch p := payload.(type) {
+		case *events.HTTPRequest:
+			if p.QueryStringParameters["fail"] != "" {
+				return nil, BadRequest("asked to fail")
+			}
+			return &events.EventResponse{Body: append([]byte(p.HTTPMethod+" "+p.Path+" "), p.Body...)}, nil
+		case *events.CloudEvent:
+			return &events.EventResponse{Body: []byte(p.Type())}, nil
+		}
+		return nil, nil
+	}
+	initialized := false
+	initializer := func(ctx context.Context) error {
+		initialized = true
+		return nil
+	}
+
+	dir, err := ioutil.TempDir("", "vefaas-invoke")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "event.json")
+	if err := ioutil.WriteFile(file, []byte(`{"specversion":"1.0","id":"1","source":"timer","type":"faas.timer.event"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		source string
+		code   int
+		output string
+	}{
+		{`{"method":"POST","path":"/orders","body":{"id":1}}`, 0, `POST /orders {"id":1}`},
+		{`{"path":"/text","body":"plain"}`, 0, "GET /text plain"},
+		{`{"query":{"fail":"1"}}`, invokeErrorExitCode, "X-Faas-Response-Error-Code: bad_request"},
+		{file, 0, "faas.timer.event"},
+		{filepath.Join(dir, "missing.json"), invokeErrorExitCode, ""},
+	}
+	for _, c := range cases {
+		server := newFunctionServer(handler, newOptions(WithInitializer(initializer)))
+		var out bytes.Buffer
+		if code := invokeOnce(server, c.source, &out); code != c.code {
+			t.Errorf("invokeOnce(%s) = %d, want %d, output: %s", c.source, code, c.code, out.String())
+		}
+		if !strings.Contains(out.String(), c.output) {
+			t.Errorf("invokeOnce(%s) output %q does not contain %q", c.source, out.String(), c.output)
+		}
+	}
+	if !initialized {
+		t.Error("initializer not executed")
+	}
+}
+
+// shutdownExporter records whether the tracer is shut down.
+type shutdownExporter struct {
+	shutdown bool
+}
+
+func (e *shutdownExporter) ExportSpans(ctx context.Context, spans []*tracing.SpanData) error {
+	return nil
+}
+
+func (e *shutdownExporter) Shutdown(ctx context.Context) error {
+	e.shutdown = true
+	return nil
+}
+
+func TestInvokeOnceInitializerFailure(t *testing.T) {
+	var invoked bool
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		invoked = true
+		return &events.EventResponse{}, nil
+	}
+	initializer := func(ctx context.Context) error {
+		return errors.New("database unreachable")
+	}
+	exporter := &shutdownExporter{}
+	server := newFunctionServer(handler, newOptions(WithInitializer(initializer), WithTraceExporter(exporter)))
+
+	var out bytes.Buffer
+	if code := invokeOnce(server, `{"path":"/"}`, &out); code != invokeErrorExitCode || invoked {
+		t.Errorf("invokeOnce() = %d, invoked %v, want %d", code, invoked, invokeErrorExitCode)
+	}
+	if !exporter.shutdown {
+		t.Error("tracer not flushed")
+	}
+}
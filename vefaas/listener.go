@@ -0,0 +1,158 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// unixAddrPrefix marks the listen address as a unix socket path.
+	unixAddrPrefix = "unix:"
+
+	// listenFdsStart is the first file descriptor passed by socket activation,
+	// see sd_listen_fds(3).
+	listenFdsStart = 3
+
+	// adminListenerName is the name of the inherited admin listener in
+	// LISTEN_FDNAMES.
+	adminListenerName = "admin"
+)
+
+// listen listens on addr, either a tcp address like ":5000", or a unix socket
+// like "unix:/tmp/vefaas.sock".
+func listen(addr string) (net.Listener, error) {
+	if strings.HasPrefix(addr, unixAddrPrefix) {
+		path := strings.TrimPrefix(addr, unixAddrPrefix)
+		// Remove the stale socket left by the previous process.
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(path)
+		}
+		return net.Listen("unix", path)
+	}
+
+	return net.Listen("tcp", addr)
+}
+
+// inheritedListeners returns the listeners passed by systemd style socket
+// activation, keyed by their names in LISTEN_FDNAMES, or by their positions if
+// unnamed. The environment variables are unset, so they are not inherited by
+// the child processes.
+func inheritedListeners() (map[string]net.Listener, []net.Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil, nil
+	}
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	named := make(map[string]net.Listener, n)
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		name := strconv.Itoa(i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(listenFdsStart+i), name)
+		l, err := net.FileListener(f)
+		// The listener holds a dup of the descriptor.
+		_ = f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, nil, fmt.Errorf("inherited file descriptor %d is not a listener, %v", listenFdsStart+i, err)
+		}
+		named[name] = l
+		listeners = append(listeners, l)
+	}
+
+	return named, listeners, nil
+}
+
+// serverListeners returns the listeners of the function and the admin server,
+// the latter is nil if not configured. The function listener is, in order of
+// precedence, the address of WithListenAddr, the first inherited listener not
+// named admin, or _FAAS_RUNTIME_PORT, a port or a unix socket. The admin
+// listener is the address of WithAdminAddr, or the inherited listener named
+// admin.
+func serverListeners(o *options) (runtime net.Listener, admin net.Listener, err error) {
+	named, inherited, err := inheritedListeners()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		// Close the inherited listeners left unused.
+		for _, l := range inherited {
+			if l != runtime && l != admin {
+				_ = l.Close()
+			}
+		}
+		if err != nil && runtime != nil {
+			_ = runtime.Close()
+			runtime, admin = nil, nil
+		}
+	}()
+
+	switch {
+	case o.listenAddr != "":
+		if runtime, err = listen(o.listenAddr); err != nil {
+			err = fmt.Errorf("listen %s, %v", o.listenAddr, err)
+			return
+		}
+	default:
+		for _, l := range inherited {
+			if l != named[adminListenerName] {
+				runtime = l
+				break
+			}
+		}
+		if runtime == nil {
+			addr := listenPort
+			if !strings.HasPrefix(addr, unixAddrPrefix) {
+				addr = ":" + addr
+			}
+			if runtime, err = listen(addr); err != nil {
+				err = fmt.Errorf("listen %s, %v", addr, err)
+				return
+			}
+		}
+	}
+
+	switch {
+	case o.adminAddr != "":
+		if admin, err = listen(o.adminAddr); err != nil {
+			err = fmt.Errorf("listen admin address %s, %v", o.adminAddr, err)
+			return
+		}
+	case named[adminListenerName] != nil:
+		admin = named[adminListenerName]
+	}
+
+	return runtime, admin, nil
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSocketActivationHelper runs in the child process of TestSocketActivation,
+// which inherits the listeners.
+func TestSocketActivationHelper(t *testing.T) {
+	if os.Getenv("VEFAAS_TEST_SOCKET_ACTIVATION") != "1" {
+		t.Skip("helper process")
+	}
+	runtime, admin, err := serverListeners(newOptions())
+	if err != nil {
+		fmt.Printf("error=%v\n", err)
+		return
+	}
+	fmt.Printf("runtime=%s\n", runtime.Addr())
+	if admin != nil {
+		fmt.Printf("admin=%s\n", admin.Addr())
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		fmt.Println("error=LISTEN_FDS not unset")
+	}
+}
+
+func TestSocketActivation(t *testing.T) {
+	var files []*os.File
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		f, err := l.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		files = append(files, f)
+		addrs = append(addrs, l.Addr().String())
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSocketActivationHelper$")
+	cmd.Env = append(os.Environ(),
+		"VEFAAS_TEST_SOCKET_ACTIVATION=1",
+		"LISTEN_FDS=2",
+		"LISTEN_FDNAMES=admin:runtime",
+	)
+	cmd.ExtraFiles = files
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("helper process error = %v, output:\n%s", err, out)
+	}
+
+	for _, want := range []string{"runtime=" + addrs[1], "admin=" + addrs[0]} {
+		if !strings.Contains(string(out), want+"\n") {
+			t.Errorf("want %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(string(out), "error=") {
+		t.Errorf("unexpected error in output:\n%s", out)
+	}
+}
+
+func TestAdminAddrInUse(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	_, _, err = serverListeners(newOptions(WithListenAddr(addr), WithAdminAddr(busy.Addr().String())))
+	if err == nil {
+		t.Fatal("want error listening the admin address in use")
+	}
+	// The runtime listener is closed on error.
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("runtime listener leaked, %v", err)
+	}
+	_ = l.Close()
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vefaas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "admin.sock")
+
+	// A stale socket is replaced.
+	for i := 0; i < 2; i++ {
+		l, err := listen(unixAddrPrefix + path)
+		if err != nil {
+			t.Fatalf("listen() error = %v", err)
+		}
+		if l.Addr().Network() != "unix" {
+			t.Errorf("network = %s, want unix", l.Addr().Network())
+		}
+		if i == 0 {
+			// Leave the socket file behind, like a crashed process.
+			l.(*net.UnixListener).SetUnlinkOnClose(false)
+		}
+		_ = l.Close()
+	}
+}
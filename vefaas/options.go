@@ -0,0 +1,319 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaas
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/idempotency"
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+	"github.com/volcengine/vefaas-golang-runtime/utils"
+)
+
+// Option configures the vefaas runtime server started by StartWithOptions.
+type Option func(*options)
+
+type options struct {
+	initializer   interface{}
+	traceExporter tracing.Exporter
+	errorRenderer utils.ErrorRenderer
+	debug         bool
+	debugSecret   string
+
+	keepPlatformHeaders bool
+
+	internalToken        string
+	internalLoopbackOnly bool
+
+	listenAddr string
+	adminAddr  string
+
+	h2c bool
+
+	webSocketSubprotocols []string
+
+	batchParallelism int
+
+	async          bool
+	asyncOnSuccess string
+	asyncOnFailure string
+
+	idempotencyStore idempotency.Store
+	idempotencyTTL   time.Duration
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		debug:       parseBool(os.Getenv("VEFAAS_DEBUG")),
+		debugSecret: os.Getenv("VEFAAS_DEBUG_SECRET"),
+
+		keepPlatformHeaders: parseBool(os.Getenv("VEFAAS_KEEP_PLATFORM_HEADERS")),
+
+		internalToken:        os.Getenv("VEFAAS_INTERNAL_TOKEN"),
+		internalLoopbackOnly: parseBool(os.Getenv("VEFAAS_INTERNAL_LOOPBACK_ONLY")),
+
+		listenAddr: os.Getenv("VEFAAS_LISTEN_ADDR"),
+		adminAddr:  os.Getenv("VEFAAS_ADMIN_ADDR"),
+
+		h2c: parseBool(os.Getenv("VEFAAS_H2C")),
+
+		batchParallelism: 1,
+
+		async:          parseBool(os.Getenv("VEFAAS_ASYNC_INVOCATION")),
+		asyncOnSuccess: os.Getenv("VEFAAS_ASYNC_ON_SUCCESS"),
+		asyncOnFailure: os.Getenv("VEFAAS_ASYNC_ON_FAILURE"),
+	}
+	if n, err := strconv.Atoi(os.Getenv("VEFAAS_BATCH_PARALLELISM")); err == nil && n > 0 {
+		o.batchParallelism = n
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	// Fallback to the environment if no exporter provided.
+	if o.traceExporter == nil {
+		if e := tracing.NewOTLPHTTPExporterFromEnv(); e != nil {
+			o.traceExporter = e
+		}
+	}
+
+	return o
+}
+
+// WithInitializer sets the function initializer, see StartWithInitializer for
+// the supported initializer signatures.
+func WithInitializer(initializer interface{}) Option {
+	return func(o *options) {
+		o.initializer = initializer
+	}
+}
+
+// WithTraceExporter sets the exporter of the invocation spans.
+//
+// If not set, spans are exported with tracing.OTLPHTTPExporter when the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT environment
+// variable is present, otherwise spans are only propagated through the handler
+// context but never exported.
+func WithTraceExporter(exporter tracing.Exporter) Option {
+	return func(o *options) {
+		o.traceExporter = exporter
+	}
+}
+
+// WithErrorRenderer sets the renderer of the bodies of error responses, which
+// applies to errors generated by the runtime as well as vefaas.Error returned
+// from function handler.
+//
+// The default is utils.ProblemJSONErrorRenderer, utils.PlainTextErrorRenderer
+// is also available, or provide your own.
+func WithErrorRenderer(renderer utils.ErrorRenderer) Option {
+	return func(o *options) {
+		o.errorRenderer = renderer
+	}
+}
+
+// WithDebug enables debug mode for all the requests, in which the error responses
+// carry the panic value, the trimmed stack trace and the error chain. It's meant
+// for staging functions only, as it exposes the internals of your function.
+//
+// The default is taken from the VEFAAS_DEBUG environment variable.
+func WithDebug(enabled bool) Option {
+	return func(o *options) {
+		o.debug = enabled
+	}
+}
+
+// WithDebugSecret enables debug mode for the requests carrying the X-Faas-Debug
+// header with secret as its value, while other requests keep the production
+// behaviour. An empty secret disables the per-request debug toggle.
+//
+// The default is taken from the VEFAAS_DEBUG_SECRET environment variable.
+func WithDebugSecret(secret string) Option {
+	return func(o *options) {
+		o.debugSecret = secret
+	}
+}
+
+// WithPlatformHeaders keeps the platform headers, like the temporary credentials
+// in X-Faas-Secret-Access-Key and X-Faas-Session-Token, in the Headers of
+// events.HTTPRequest. They are stripped by default, so that handlers logging the
+// request headers don't leak them, use the vefaascontext package to access the
+// credentials instead. See utils.IsPlatformHeader for the headers.
+//
+// The default is taken from the VEFAAS_KEEP_PLATFORM_HEADERS environment variable.
+func WithPlatformHeaders(keep bool) Option {
+	return func(o *options) {
+		o.keepPlatformHeaders = keep
+	}
+}
+
+// WithInternalToken requires the internal requests of the platform, like
+// /v1/initialize, to carry token in the X-Faas-Internal-Token header. Requests
+// failing the check are handled as ordinary invocations, with the internal
+// headers stripped. Without a token, the internal requests are accepted from
+// any address, as sent by the platform, unless restricted by
+// WithInternalLoopbackOnly. Either is recommended when the function port is
+// reachable by others than the platform.
+//
+// The default is taken from the VEFAAS_INTERNAL_TOKEN environment variable.
+func WithInternalToken(token string) Option {
+	return func(o *options) {
+		o.internalToken = token
+	}
+}
+
+// WithInternalLoopbackOnly only accepts the internal requests of the platform
+// from loopback addresses or unix sockets, in addition to the internal token
+// if configured, see WithInternalToken.
+//
+// The default is taken from the VEFAAS_INTERNAL_LOOPBACK_ONLY environment variable.
+func WithInternalLoopbackOnly(enabled bool) Option {
+	return func(o *options) {
+		o.internalLoopbackOnly = enabled
+	}
+}
+
+// WithListenAddr serves the function at addr, either a tcp address like
+// "127.0.0.1:5000", or a unix socket like "unix:/tmp/vefaas.sock", instead of
+// the port given by the platform.
+//
+// Without it, the function is served on the first listener passed by systemd
+// style socket activation (LISTEN_FDS), if any, except the one named "admin" in
+// LISTEN_FDNAMES, which serves as the admin listener. Otherwise, it's served on
+// _FAAS_RUNTIME_PORT, which is also allowed to be a "unix:" socket path.
+//
+// The default is taken from the VEFAAS_LISTEN_ADDR environment variable.
+func WithListenAddr(addr string) Option {
+	return func(o *options) {
+		o.listenAddr = addr
+	}
+}
+
+// WithAdminAddr serves the internal and diagnostic endpoints on a separate admin
+// listener at addr, either a tcp address like "127.0.0.1:9000", or a unix socket
+// like "unix:/tmp/vefaas-admin.sock". The admin listener serves
+//   - /v1/initialize and /v1/version,
+//   - /healthz, for readiness and liveness probes,
+//   - /metrics, the invocation metrics in Prometheus text format,
+//   - /debug/pprof/, the runtime profiles.
+//
+// The diagnostic endpoints are never served on the function port, so they are
+// not reachable through triggers, nor are the internal requests once the admin
+// listener is set up. The default is taken from the
+// VEFAAS_ADMIN_ADDR environment variable, no admin listener if empty.
+func WithAdminAddr(addr string) Option {
+	return func(o *options) {
+		o.adminAddr = addr
+	}
+}
+
+// WithH2C serves HTTP/2 cleartext with prior knowledge on the function listener
+// besides HTTP/1, so the gateway or a local proxy can multiplex concurrent
+// invocations over one connection.
+//
+// The default is taken from the VEFAAS_H2C environment variable.
+func WithH2C(enabled bool) Option {
+	return func(o *options) {
+		o.h2c = enabled
+	}
+}
+
+// WithWebSocketSubprotocols sets the subprotocols supported by the WebSocket
+// handler, in the Sec-WebSocket-Protocol header of the handshake. The first one
+// requested by the client that is supported is selected, and available as
+// websocket.Conn.Subprotocol. No subprotocol is selected by default.
+func WithWebSocketSubprotocols(subprotocols ...string) Option {
+	return func(o *options) {
+		o.webSocketSubprotocols = subprotocols
+	}
+}
+
+// WithBatchParallelism sets how many events of a CloudEvent batch are handled
+// concurrently, when batches are dispatched event by event to a handler of single
+// CloudEvents. The default is 1, which handles the events in order.
+//
+// The default is taken from the VEFAAS_BATCH_PARALLELISM environment variable.
+func WithBatchParallelism(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.batchParallelism = n
+		}
+	}
+}
+
+// WithAsyncInvocation enables the asynchronous invocations, requested with the
+// X-Faas-Invocation-Type: async header. They are answered with 202 right away,
+// while the function runs in the background under the function timeout, and
+// its result is delivered to the destinations set by WithAsyncDestinations.
+// The runtime waits for the pending asynchronous invocations on shutdown.
+//
+// The default is taken from the VEFAAS_ASYNC_INVOCATION environment variable.
+func WithAsyncInvocation(enabled bool) Option {
+	return func(o *options) {
+		o.async = enabled
+	}
+}
+
+// WithAsyncDestinations sets the http endpoints receiving the results of the
+// asynchronous invocations, as binary mode CloudEvents of type
+// events.FaasAsyncSuccessEvent or events.FaasAsyncFailureEvent carrying
+// events.AsyncInvocationResult. An invocation failed if it responded with an
+// error or a status code of 400 and above. Results without a destination are
+// dropped, the failures are logged.
+//
+// The defaults are taken from the VEFAAS_ASYNC_ON_SUCCESS and
+// VEFAAS_ASYNC_ON_FAILURE environment variables.
+func WithAsyncDestinations(onSuccess, onFailure string) Option {
+	return func(o *options) {
+		o.asyncOnSuccess = onSuccess
+		o.asyncOnFailure = onFailure
+	}
+}
+
+// WithIdempotency suppresses the duplicate invocations, like the CloudEvents
+// redelivered by kafka and rocketmq triggers, or the http requests retried by
+// the clients. The invocations are keyed on the id and source of CloudEvents,
+// or the method, path and Idempotency-Key header of http requests, and those
+// without a key are handled as usual. For a key, the first invocation is
+// handled, while the duplicates get
+//   - a 422 error, if the http request body differs from the first one,
+//   - a 409 conflict error, while the first invocation is in progress,
+//   - the response of the first invocation, with the X-Faas-Idempotent-Replayed
+//     header, for ttl once it completed.
+//
+// The keys of the invocations returning errors, 5xx or streaming responses, or
+// reporting failed messages with KafkaMessageHandler or RocketMqMessageHandler,
+// are released, so they can be retried. The records are kept in store, like
+// idempotency.NewMemoryStore or idempotency.NewFileStore. DefaultIdempotencyTTL
+// applies if ttl is not positive.
+//
+// Batches of CloudEvents dispatched event by event are keyed on each event,
+// while the handlers of []*events.CloudEvent, and the batches passed to the
+// handlers of interface{}, are not covered.
+func WithIdempotency(store idempotency.Store, ttl time.Duration) Option {
+	return func(o *options) {
+		o.idempotencyStore = store
+		o.idempotencyTTL = ttl
+	}
+}
+
+func parseBool(s string) bool {
+	b, _ := strconv.ParseBool(s)
+	return b
+}
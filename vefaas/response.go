@@ -0,0 +1,231 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaas
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+	"github.com/volcengine/vefaas-golang-runtime/utils"
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+)
+
+// withInvocationContext stores the invocation metadata carried by the request
+// headers into ctx.
+func withInvocationContext(ctx context.Context, rq *http.Request) context.Context {
+	ctx = vefaascontext.WithRequestIdContext(ctx, rq)
+	ctx = vefaascontext.WithCredentialsContext(ctx, rq)
+
+	return ctx
+}
+
+// recoverFunction recovers from the panic raised by function handler, records
+// it on the invocation span and reports it to the caller.
+func recoverFunction(ctx context.Context, rw http.ResponseWriter) {
+	if err := recover(); err != nil {
+		stack := debug.Stack()
+		tracing.SpanFromContext(ctx).RecordPanic(err, stack)
+		utils.SetFunctionPanicHeader(rw, err, stack)
+	}
+}
+
+// writeEventResponse writes the result of function handler started at startTime.
+func writeEventResponse(ctx context.Context, rw http.ResponseWriter, startTime time.Time, resp *events.EventResponse, err error) {
+	utils.SetExecutionDurationHeader(rw, startTime)
+
+	if err != nil {
+		var fe *Error
+		if !errors.As(err, &fe) {
+			tracing.SpanFromContext(ctx).RecordError(err)
+			utils.SetFunctionExecutionErrorHeader(rw, err)
+			return
+		}
+		// Client errors are expected results rather than failures of the function.
+		if fe.StatusCode == 0 || fe.StatusCode >= http.StatusInternalServerError {
+			tracing.SpanFromContext(ctx).RecordError(err)
+		}
+		writeError(rw, fe)
+		return
+	}
+	if resp == nil {
+		utils.SetFunctionNoResponseErrorHeader(rw)
+		return
+	}
+
+	if resp.Headers != nil {
+		for k, v := range resp.Headers {
+			rw.Header().Set(k, v)
+		}
+	}
+	// In case user set this response header, we need to rewrite it here.
+	utils.SetExecutionDurationHeader(rw, startTime)
+
+	if resp.StatusCode != 0 {
+		rw.WriteHeader(resp.StatusCode)
+	}
+	if resp.BodyStream != nil {
+		writeBodyStream(ctx, rw, resp.BodyStream)
+		return
+	}
+	if resp.Body != nil {
+		_, _ = rw.Write(resp.Body)
+	}
+}
+
+// writeBodyStream streams the response body, flushing every write. The status
+// code has been sent, so a failure is only reported to the log and the span.
+func writeBodyStream(ctx context.Context, rw http.ResponseWriter, stream func(context.Context, io.Writer) error) {
+	w := &flushWriter{ResponseWriter: rw}
+	w.Flush()
+	if err := stream(ctx, w); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "Failed to stream response body, %v.\n", err)
+		tracing.SpanFromContext(ctx).RecordError(err)
+	}
+}
+
+// flushWriter flushes the response after every write.
+type flushWriter struct {
+	http.ResponseWriter
+}
+
+func (w *flushWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	if err == nil {
+		w.Flush()
+	}
+	return n, err
+}
+
+func (w *flushWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// bufferedResponseWriter is an http.ResponseWriter keeping the response in memory.
+type bufferedResponseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: make(http.Header)}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+// responseRecorder records the status code written through the wrapped
+// http.ResponseWriter, and carries how to render the error responses.
+type responseRecorder struct {
+	http.ResponseWriter
+
+	statusCode int
+	renderer   utils.ErrorRenderer
+	debug      bool
+}
+
+// ErrorRenderer returns the renderer of the error responses of the invocation,
+// see utils.WriteErrorResponse.
+func (r *responseRecorder) ErrorRenderer() utils.ErrorRenderer {
+	return r.renderer
+}
+
+// DebugEnabled reports whether the error responses of the invocation carry the
+// debug information, see utils.WriteErrorResponse.
+func (r *responseRecorder) DebugEnabled() bool {
+	return r.debug
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, which is required by streaming handlers like
+// gRPC, if the underlying http.ResponseWriter supports it.
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		if r.statusCode == 0 {
+			r.statusCode = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, which is required by WebSocket, if the
+// underlying http.ResponseWriter supports it.
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not implement http.Hijacker")
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil && r.statusCode == 0 {
+		r.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter, for http.ResponseController.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// status returns the recorded status code, which defaults to 200 if nothing
+// has been written.
+func (r *responseRecorder) status() int {
+	if r.statusCode == 0 {
+		return http.StatusOK
+	}
+	return r.statusCode
+}
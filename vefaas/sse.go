@@ -0,0 +1,173 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+// DefaultSSEKeepAlive is the default interval of the keep-alive comments of SSE.
+const DefaultSSEKeepAlive = 15 * time.Second
+
+// SSEOption configures the stream created by SSE.
+type SSEOption func(*sseOptions)
+
+type sseOptions struct {
+	keepAlive time.Duration
+}
+
+// WithSSEKeepAlive sets the interval of the keep-alive comments sent while the
+// stream is idle, which stop the proxies in between from closing the idle
+// connection. Zero disables keep-alive.
+func WithSSEKeepAlive(interval time.Duration) SSEOption {
+	return func(o *sseOptions) {
+		o.keepAlive = interval
+	}
+}
+
+// SSE creates a response streaming Server-Sent Events produced by produce, for
+// streaming progress or tokens of AI models from HTTP handlers:
+//
+//	return vefaas.SSE(func(s *vefaas.SSEStream) error {
+//		for token := range tokens {
+//			if err := s.Send("token", token, ""); err != nil {
+//				return err
+//			}
+//		}
+//		return nil
+//	}), nil
+//
+// The stream is cancelled when the client disconnects or the function times
+// out, then Send fails and s.Context() is done. produce returning ends the
+// stream.
+func SSE(produce func(s *SSEStream) error, opts ...SSEOption) *events.EventResponse {
+	o := &sseOptions{keepAlive: DefaultSSEKeepAlive}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &events.EventResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Content-Type":  "text/event-stream; charset=utf-8",
+			"Cache-Control": "no-cache",
+			// Disable the response buffering of nginx like proxies.
+			"X-Accel-Buffering": "no",
+		},
+		BodyStream: func(ctx context.Context, w io.Writer) error {
+			ctx, cancel := context.WithCancel(ctx)
+			s := &SSEStream{ctx: ctx, w: w}
+			if o.keepAlive > 0 {
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					s.keepAlive(o.keepAlive)
+				}()
+				// The response must not be written after the stream returns.
+				defer func() { <-done }()
+			}
+			defer cancel()
+
+			return produce(s)
+		},
+	}
+}
+
+// SSEStream writes Server-Sent Events, it's safe for concurrent use.
+type SSEStream struct {
+	ctx context.Context
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Context returns the context of the stream, which is done once the client
+// disconnects, the function times out, or the stream ends.
+func (s *SSEStream) Context() context.Context {
+	return s.ctx
+}
+
+// Send sends an event with data, the event type and id are omitted if empty.
+// Multi-line data is sent as multiple data lines.
+func (s *SSEStream) Send(event, data, id string) error {
+	var b strings.Builder
+	if id != "" {
+		b.WriteString("id: " + sseField(id) + "\n")
+	}
+	if event != "" {
+		b.WriteString("event: " + sseField(event) + "\n")
+	}
+	data = strings.ReplaceAll(strings.ReplaceAll(data, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// SendJSON sends an event with the json encoding of v as data.
+func (s *SSEStream) SendJSON(event string, v interface{}, id string) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Send(event, string(data), id)
+}
+
+// Comment sends a comment, which is ignored by clients.
+func (s *SSEStream) Comment(text string) error {
+	return s.write(": " + sseField(text) + "\n\n")
+}
+
+func (s *SSEStream) write(frame string) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := io.WriteString(s.w, frame)
+	return err
+}
+
+func (s *SSEStream) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Comment("keep-alive"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sseField strips the line breaks, which would end the field.
+func sseField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+)
+
+func TestSSE(t *testing.T) {
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		return SSE(func(s *SSEStream) error {
+			if err := s.Send("token", "hello\nworld", "1"); err != nil {
+				return err
+			}
+			return s.SendJSON("", map[string]int{"n": 2}, "")
+		}, WithSSEKeepAlive(0)), nil
+	}
+	ts := httptest.NewServer(newFunctionServer(handler, newOptions()))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	want := "id: 1\nevent: token\ndata: hello\ndata: world\n\ndata: {\"n\":2}\n\n"
+	if string(body) != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestSSEKeepAlive(t *testing.T) {
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		return SSE(func(s *SSEStream) error {
+			<-s.Context().Done()
+			return nil
+		}, WithSSEKeepAlive(10*time.Millisecond)), nil
+	}
+	ts := httptest.NewServer(newFunctionServer(handler, newOptions()))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != ": keep-alive\n" {
+		t.Errorf("ReadString() = %q, %v, want keep-alive comment", line, err)
+	}
+}
+
+func TestSSEClientDisconnect(t *testing.T) {
+	cancelled := make(chan error, 1)
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		return SSE(func(s *SSEStream) error {
+			if err := s.Send("", "ready", ""); err != nil {
+				return err
+			}
+			<-s.Context().Done()
+			cancelled <- s.Send("", "late", "")
+			return nil
+		}), nil
+	}
+	ts := httptest.NewServer(newFunctionServer(handler, newOptions()))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rq, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	resp, err := http.DefaultClient.Do(rq)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+	if line, _ := bufio.NewReader(resp.Body).ReadString('\n'); !strings.HasPrefix(line, "data: ready") {
+		t.Fatalf("ReadString() = %q", line)
+	}
+	cancel()
+
+	select {
+	case err := <-cancelled:
+		if err == nil {
+			t.Error("Send() after disconnect error = nil")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not cancelled after client disconnect")
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaas
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cloudevents/sdk-go/v2/extensions"
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+)
+
+// startInvocationSpan starts the server span of an invocation, parented from the
+// W3C trace context headers, or the distributed tracing extension of a binary
+// mode CloudEvent. The W3C baggage is also carried by the returned context.
+func startInvocationSpan(tracer *tracing.Tracer, rq *http.Request) (context.Context, *tracing.Span) {
+	parent, ok := tracing.SpanContextFromHeader(rq.Header)
+	if !ok {
+		parent, _ = tracing.ParseTraceparent(rq.Header.Get("Ce-Traceparent"), rq.Header.Get("Ce-Tracestate"))
+	}
+
+	name := rq.Method
+	trigger := "http"
+	if rq.Header.Get("X-Faas-Event-Type") == events.EventTypeCloudEvent {
+		name = events.EventTypeCloudEvent
+		trigger = "pubsub"
+	}
+
+	ctx := rq.Context()
+	if baggage := tracing.BaggageFromHeader(rq.Header); baggage != "" {
+		ctx = tracing.ContextWithBaggage(ctx, baggage)
+	}
+	ctx, span := tracer.Start(ctx, name, tracing.SpanKindServer, parent)
+	span.SetAttribute("faas.trigger", trigger)
+	span.SetAttribute("faas.invocation_id", rq.Header.Get("X-Faas-Request-Id"))
+	span.SetAttribute("http.request.method", rq.Method)
+	span.SetAttribute("url.path", rq.URL.Path)
+
+	return ctx, span
+}
+
+// annotateCloudEventSpan decorates the invocation span with the attributes of
+// event, and links it to the distributed tracing extension of event if the span
+// has no parent yet, which is the case for structured mode CloudEvents.
+func annotateCloudEventSpan(ctx context.Context, event *events.CloudEvent) {
+	span := tracing.SpanFromContext(ctx)
+	if span == nil || event == nil || event.Event == nil {
+		return
+	}
+
+	if dt, ok := extensions.GetDistributedTracingExtension(*event.Event); ok {
+		if parent, ok := tracing.ParseTraceparent(dt.TraceParent, dt.TraceState); ok {
+			span.SetParent(parent)
+		}
+	}
+
+	span.SetName(event.Type())
+	span.SetAttribute("faas.trigger", cloudEventTrigger(event.Type()))
+	span.SetAttribute("cloudevents.event_id", event.ID())
+	span.SetAttribute("cloudevents.event_source", event.Source())
+	span.SetAttribute("cloudevents.event_type", event.Type())
+	if subject := event.Subject(); subject != "" {
+		span.SetAttribute("cloudevents.event_subject", subject)
+	}
+}
+
+// endInvocationSpan records the outcome of the invocation on span and ends it.
+func endInvocationSpan(span *tracing.Span, rec *responseRecorder) {
+	if span == nil {
+		return
+	}
+
+	status := rec.status()
+	span.SetAttribute("http.response.status_code", status)
+	code := rec.Header().Get("X-Faas-Response-Error-Code")
+	if code != "" {
+		span.SetAttribute("vefaas.error_code", code)
+	}
+	// Only server errors mark the span as failed, see the OpenTelemetry semantic
+	// conventions of http server spans.
+	if status >= http.StatusInternalServerError {
+		message := rec.Header().Get("X-Faas-Response-Error-Message")
+		if message == "" {
+			message = http.StatusText(status)
+		}
+		span.SetStatus(tracing.StatusError, message)
+	}
+	span.End()
+}
+
+// cloudEventTrigger maps the CloudEvent type to the OpenTelemetry faas.trigger value.
+func cloudEventTrigger(eventType string) string {
+	switch eventType {
+	case events.FaasTimerEvent:
+		return "timer"
+	case events.FaasTosEvent:
+		return "datasource"
+	default:
+		return "pubsub"
+	}
+}
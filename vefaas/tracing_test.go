@@ -0,0 +1,146 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaas
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+)
+
+// collector is a local stand-in of an OTLP/HTTP collector.
+type collector struct {
+	mu    sync.Mutex
+	spans []map[string]interface{}
+}
+
+func (c *collector) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []map[string]interface{} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+func attribute(span map[string]interface{}, key string) interface{} {
+	attrs, _ := span["attributes"].([]interface{})
+	for _, a := range attrs {
+		kv := a.(map[string]interface{})
+		if kv["key"] == key {
+			for _, v := range kv["value"].(map[string]interface{}) {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func TestInvocationSpanExported(t *testing.T) {
+	c := &collector{}
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+
+	var handlerSpan tracing.SpanContext
+	handler := func(ctx context.Context, payload interface{}) (*events.EventResponse, error) {
+		handlerSpan = tracing.SpanFromContext(ctx).SpanContext()
+		if r, ok := payload.(*events.HTTPRequest); ok && r.Path == "/panic" {
+			panic("boom")
+		}
+		return &events.EventResponse{Body: []byte("ok")}, nil
+	}
+
+	tracer := tracing.NewTracer(tracing.NewOTLPHTTPExporter(ts.URL + "/v1/traces"))
+	eventType, functionHandler := validateHandler(handler)
+	server := &functionServer{handleFunc: buildHandler(eventType, functionHandler, nil, &options{}), tracer: tracer}
+
+	// HTTP request parented from traceparent header.
+	rq := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	rq.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rq.Header.Set("X-Faas-Request-Id", "req-1")
+	server.ServeHTTP(httptest.NewRecorder(), rq)
+	if got := handlerSpan.TraceID.String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("handler trace id = %s", got)
+	}
+
+	// Structured CloudEvent parented from the distributed tracing extension.
+	rq = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"specversion":"1.0","id":"1",`+
+		`"source":"timer","type":"faas.timer.event","traceparent":"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}`))
+	rq.Header.Set("Content-Type", "application/cloudevents+json")
+	rq.Header.Set("X-Faas-Event-Type", events.EventTypeCloudEvent)
+	server.ServeHTTP(httptest.NewRecorder(), rq)
+
+	// Panics are recorded on the span.
+	rq = httptest.NewRequest(http.MethodGet, "/panic", nil)
+	server.ServeHTTP(httptest.NewRecorder(), rq)
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown tracer: %v", err)
+	}
+
+	if len(c.spans) != 3 {
+		t.Fatalf("collector received %d spans, want 3", len(c.spans))
+	}
+	httpSpan, ceSpan, panicSpan := c.spans[0], c.spans[1], c.spans[2]
+
+	if httpSpan["traceId"] != "4bf92f3577b34da6a3ce929d0e0e4736" || httpSpan["parentSpanId"] != "00f067aa0ba902b7" {
+		t.Errorf("http span not parented from traceparent: %v", httpSpan)
+	}
+	if httpSpan["kind"] != float64(tracing.SpanKindServer) || attribute(httpSpan, "faas.invocation_id") != "req-1" {
+		t.Errorf("unexpected http span %v", httpSpan)
+	}
+
+	if ceSpan["traceId"] != "0af7651916cd43dd8448eb211c80319c" || ceSpan["parentSpanId"] != "b7ad6b7169203331" {
+		t.Errorf("cloudevent span not parented from extension: %v", ceSpan)
+	}
+	if ceSpan["name"] != events.FaasTimerEvent || attribute(ceSpan, "faas.trigger") != "timer" {
+		t.Errorf("unexpected cloudevent span %v", ceSpan)
+	}
+
+	status, _ := panicSpan["status"].(map[string]interface{})
+	if status["code"] != float64(tracing.StatusError) || attribute(panicSpan, "vefaas.error_code") != "function_panic" {
+		t.Errorf("panic not recorded on span: %v", panicSpan)
+	}
+	if evs, _ := panicSpan["events"].([]interface{}); len(evs) != 1 {
+		t.Errorf("panic span has %d events, want 1", len(evs))
+	}
+}
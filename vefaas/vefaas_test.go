@@ -70,3 +70,23 @@ func ExampleStartWithInitializer() {
 	// Start your vefaas function =D.
 	StartWithInitializer(handler, initializer)
 }
+
+// ExampleBadRequest shows how to report a client error with its own status code and error code.
+func ExampleBadRequest() {
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		var order struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(r.Body, &order); err != nil {
+			// Responds 400 with X-Faas-Response-Error-Code: bad_request and a json error body.
+			return nil, BadRequest("invalid order: %v", err).WithDetails(map[string]string{"body": string(r.Body)})
+		}
+		if order.ID == "" {
+			return nil, BadRequest("order id is required")
+		}
+
+		return &events.EventResponse{Body: []byte(order.ID)}, nil
+	}
+
+	Start(handler)
+}
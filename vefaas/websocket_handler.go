@@ -0,0 +1,158 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/tracing"
+	"github.com/volcengine/vefaas-golang-runtime/utils"
+	"github.com/volcengine/vefaas-golang-runtime/websocket"
+)
+
+// webSocketTracker tracks the WebSocket connections of a functionServer, which
+// outlive the graceful shutdown of http.Server once hijacked.
+type webSocketTracker struct {
+	wg sync.WaitGroup
+
+	// mu guards closing, so no connection is added once closeAll waits.
+	mu       sync.Mutex
+	closing  bool
+	shutdown chan struct{}
+}
+
+func newWebSocketTracker() *webSocketTracker {
+	return &webSocketTracker{shutdown: make(chan struct{})}
+}
+
+// closeAll closes all the connections with websocket.CloseGoingAway, and waits
+// for their handlers to return until ctx is done.
+func (t *webSocketTracker) closeAll(ctx context.Context) error {
+	t.mu.Lock()
+	if !t.closing {
+		t.closing = true
+		close(t.shutdown)
+	}
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// add tracks a new connection, unless closeAll is called already.
+func (t *webSocketTracker) add() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing {
+		return false
+	}
+	t.wg.Add(1)
+	return true
+}
+
+func handleWebSocket(handler interface{}, tracker *webSocketTracker, subprotocols []string) func(rw http.ResponseWriter, rq *http.Request) {
+	functionHandler := handler.(webSocketFunctionHandler)
+	return func(rw http.ResponseWriter, rq *http.Request) {
+		defer recoverFunction(rq.Context(), rw)
+
+		eventType := rq.Header.Get("X-Faas-Event-Type")
+		if eventType != "" && eventType != events.EventTypeHTTP {
+			utils.SetInvalidEventTypeHeader(rw, eventType, events.EventTypeHTTP)
+			return
+		}
+		if err := websocket.CheckUpgrade(rq); err != nil {
+			he := err.(*websocket.HandshakeError)
+			if he.StatusCode == http.StatusUpgradeRequired {
+				rw.Header().Set("Sec-Websocket-Version", "13")
+			}
+			utils.SetInvalidWebSocketUpgradeHeader(rw, he.StatusCode, err)
+			return
+		}
+
+		if !tracker.add() {
+			utils.WriteErrorResponse(rw, &utils.ErrorResponse{
+				StatusCode: http.StatusServiceUnavailable,
+				Code:       "server_shutting_down",
+				Message:    "The server is shutting down.",
+				Retryable:  true,
+			})
+			return
+		}
+		defer tracker.wg.Done()
+
+		ctx, cancel := context.WithCancel(withInvocationContext(rq.Context(), rq))
+		defer cancel()
+
+		conn, err := websocket.Upgrade(rw, rq, subprotocols...)
+		if err != nil {
+			return
+		}
+
+		go func() {
+			select {
+			case <-conn.Done():
+			case <-ctx.Done():
+				_ = conn.Close(websocket.CloseGoingAway, "function timeout")
+			case <-tracker.shutdown:
+				_ = conn.Close(websocket.CloseGoingAway, "server shutting down")
+				cancel()
+			}
+		}()
+
+		serveWebSocket(ctx, functionHandler, conn)
+	}
+}
+
+// serveWebSocket runs the handler of conn, and closes conn once it returns.
+func serveWebSocket(ctx context.Context, handler webSocketFunctionHandler, conn *websocket.Conn) {
+	span := tracing.SpanFromContext(ctx)
+	defer func() {
+		if err := recover(); err != nil {
+			stack := debug.Stack()
+			_, _ = fmt.Fprintf(os.Stderr, "panic: %v\n%s", err, stack)
+			span.RecordPanic(err, stack)
+			_ = conn.Close(websocket.CloseInternalServerErr, "function panic")
+		}
+	}()
+
+	err := handler(ctx, conn)
+	if ctx.Err() != nil {
+		// Closed by the watcher with the reason of the cancellation.
+		<-conn.Done()
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+		_ = conn.Close(websocket.CloseInternalServerErr, "function error")
+		return
+	}
+	_ = conn.Close(websocket.CloseNormalClosure, "")
+}
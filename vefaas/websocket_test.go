@@ -0,0 +1,164 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package vefaas
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+	"github.com/volcengine/vefaas-golang-runtime/websocket"
+)
+
+func dialFunction(t *testing.T, handler interface{}) (*websocket.Conn, func()) {
+	conn, _, closeServer := dialFunctionServer(t, newFunctionServer(handler, newOptions()))
+	return conn, closeServer
+}
+
+func dialFunctionServer(t *testing.T, server *functionServer) (*websocket.Conn, *http.Response, func()) {
+	ts := httptest.NewServer(server)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	header := http.Header{"X-Faas-Request-Id": {"req-1"}, "X-Faas-Event-Type": {"http"}}
+	conn, resp, err := websocket.Dial(ctx, ts.URL, header)
+	if err != nil && resp == nil {
+		ts.Close()
+		t.Fatalf("Dial() error = %v", err)
+	}
+	return conn, resp, ts.Close
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	handler := func(ctx context.Context, conn *websocket.Conn) error {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if err := conn.WriteMessage(messageType, append(data, " "+vefaascontext.RequestIdFromContext(ctx)...)); err != nil {
+			return err
+		}
+		if string(data) == "fail" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+
+	cases := []struct {
+		message string
+		code    int
+	}{
+		{"hello", websocket.CloseNormalClosure},
+		{"fail", websocket.CloseInternalServerErr},
+	}
+	for _, c := range cases {
+		conn, closeServer := dialFunction(t, handler)
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(c.message))
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != c.message+" req-1" {
+			t.Errorf("ReadMessage() = %q, %v, want %q", data, err, c.message+" req-1")
+		}
+		if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, c.code) {
+			t.Errorf("ReadMessage() error = %v, want close %d", err, c.code)
+		}
+		closeServer()
+	}
+}
+
+func TestWebSocketUpgradeRequired(t *testing.T) {
+	handler := func(ctx context.Context, conn *websocket.Conn) error { return nil }
+	rw := httptest.NewRecorder()
+	newFunctionServer(handler, newOptions()).ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rw.Code != http.StatusUpgradeRequired || rw.Header().Get("X-Faas-Response-Error-Code") != "invalid_websocket_upgrade" {
+		t.Errorf("status = %d, error code = %q", rw.Code, rw.Header().Get("X-Faas-Response-Error-Code"))
+	}
+}
+
+func TestWebSocketTimeout(t *testing.T) {
+	defer func(timeout int) { requestTimeoutSecond = timeout }(requestTimeoutSecond)
+	requestTimeoutSecond = 1
+
+	handler := func(ctx context.Context, conn *websocket.Conn) error {
+		<-ctx.Done()
+		return nil
+	}
+	conn, closeServer := dialFunction(t, handler)
+	defer closeServer()
+
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("ReadMessage() error = %v, want close %d", err, websocket.CloseGoingAway)
+	}
+}
+
+func TestWebSocketShutdown(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	handler := func(ctx context.Context, conn *websocket.Conn) error {
+		<-ctx.Done()
+		cancelled <- struct{}{}
+		return nil
+	}
+	server := newFunctionServer(handler, newOptions())
+	conn, _, closeServer := dialFunctionServer(t, server)
+	defer closeServer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.webSockets.closeAll(ctx); err != nil {
+		t.Fatalf("closeAll() error = %v", err)
+	}
+	<-cancelled
+
+	_, _, err := conn.ReadMessage()
+	var ce *websocket.CloseError
+	if !errors.As(err, &ce) || ce.Code != websocket.CloseGoingAway || ce.Text != "server shutting down" {
+		t.Errorf("ReadMessage() error = %v, want close %d", err, websocket.CloseGoingAway)
+	}
+
+	// New connections are refused by the server shutting down only.
+	_, resp, closeRefused := dialFunctionServer(t, server)
+	defer closeRefused()
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("dial the server shutting down, got %v, want status %d", resp, http.StatusServiceUnavailable)
+	}
+	conn, closeOther := dialFunction(t, func(ctx context.Context, conn *websocket.Conn) error { return nil })
+	defer closeOther()
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("ReadMessage() error = %v, want close %d", err, websocket.CloseNormalClosure)
+	}
+}
+
+func TestWebSocketSubprotocols(t *testing.T) {
+	handler := func(ctx context.Context, conn *websocket.Conn) error {
+		return conn.WriteMessage(websocket.TextMessage, []byte(conn.Subprotocol))
+	}
+	ts := httptest.NewServer(newFunctionServer(handler, newOptions(WithWebSocketSubprotocols("v1", "v2"))))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, ts.URL, http.Header{"Sec-Websocket-Protocol": {"v3, v2, v1"}})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || conn.Subprotocol != "v2" || string(data) != "v2" {
+		t.Errorf("subprotocol = %q, handler saw %q, %v, want v2", conn.Subprotocol, data, err)
+	}
+	_ = conn.Close(websocket.CloseNormalClosure, "")
+}
@@ -35,6 +35,7 @@ const (
 	accessKeyIdContextKey
 	secretAccessKeyContextKey
 	sessionTokenContextKey
+	credentialsExpirationContextKey
 )
 
 // WithRequestIdContext stores request id into context.
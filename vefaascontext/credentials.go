@@ -0,0 +1,113 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaascontext
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// credentialsExpirationHeader carries the expiration time of the temporary
+// credentials in RFC 3339 format, when provided by the platform.
+const credentialsExpirationHeader = "X-Faas-Credentials-Expiration"
+
+// Credentials is the temporary credentials the function is granted with.
+type Credentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// Expiration is the time the credentials expire at, it's zero if unknown.
+	Expiration time.Time
+}
+
+// IsValid reports whether the access key id and secret access key are present.
+func (c Credentials) IsValid() bool {
+	return c.AccessKeyId != "" && c.SecretAccessKey != ""
+}
+
+// Expired reports whether the credentials have expired at t. Credentials without
+// known expiration never expire.
+func (c Credentials) Expired(t time.Time) bool {
+	return !c.Expiration.IsZero() && !t.Before(c.Expiration)
+}
+
+// Equal reports whether c and other are the same credentials.
+func (c Credentials) Equal(other Credentials) bool {
+	return c.AccessKeyId == other.AccessKeyId &&
+		c.SecretAccessKey == other.SecretAccessKey &&
+		c.SessionToken == other.SessionToken &&
+		c.Expiration.Equal(other.Expiration)
+}
+
+// WithCredentialsContext stores the temporary credentials of req into context.
+// They are not recorded as the latest credentials of the process, as the
+// headers of the invocations may be set by the callers, see
+// RecordLatestCredentials.
+func WithCredentialsContext(ctx context.Context, req *http.Request) context.Context {
+	ctx = WithAccessKeyIdContext(ctx, req)
+	ctx = WithSecretAccessKeyContext(ctx, req)
+	ctx = WithSessionTokenContext(ctx, req)
+	if expiration, err := time.Parse(time.RFC3339, req.Header.Get(credentialsExpirationHeader)); err == nil {
+		ctx = context.WithValue(ctx, credentialsExpirationContextKey, expiration)
+	}
+
+	return ctx
+}
+
+// CredentialsFromContext retrieves the temporary credentials from context.
+func CredentialsFromContext(ctx context.Context) (c Credentials) {
+	if ctx == nil {
+		return
+	}
+	c.AccessKeyId = AccessKeyIdFromContext(ctx)
+	c.SecretAccessKey = SecretAccessKeyFromContext(ctx)
+	c.SessionToken = SessionTokenFromContext(ctx)
+	c.Expiration, _ = ctx.Value(credentialsExpirationContextKey).(time.Time)
+
+	return
+}
+
+var latestCredentials struct {
+	sync.RWMutex
+	Credentials
+}
+
+// LatestCredentials returns the most recent valid credentials handed out by the
+// platform to the process, see RecordLatestCredentials. It's useful for the
+// clients created in initializer, which outlive the invocations.
+func LatestCredentials() Credentials {
+	latestCredentials.RLock()
+	defer latestCredentials.RUnlock()
+
+	return latestCredentials.Credentials
+}
+
+// RecordLatestCredentials records c as the latest credentials of the process if
+// valid. The runtime records the credentials of the authenticated internal
+// requests of the platform only, like the initialization.
+func RecordLatestCredentials(c Credentials) {
+	if !c.IsValid() {
+		return
+	}
+	latestCredentials.Lock()
+	defer latestCredentials.Unlock()
+
+	latestCredentials.Credentials = c
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package volccreds provides the function credentials to the clients of the
+// Volcengine Go SDK (github.com/volcengine/volcengine-go-sdk).
+//
+// Use NewCredentials for the clients created per invocation:
+//
+//	config := volcengine.NewConfig().
+//		WithRegion("cn-beijing").
+//		WithCredentials(volccreds.NewCredentials(ctx))
+//
+// and NewProcessCredentials for the clients created once in initializer, which
+// follow the credentials rotated by the platform.
+package volccreds
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+	"github.com/volcengine/volcengine-go-sdk/volcengine/credentials"
+)
+
+// ProviderName is the name of the provider reported in credentials.Value.
+const ProviderName = "VefaasProvider"
+
+// ErrNoCredentials is returned by Provider.Retrieve if no credentials are available,
+// like when running outside vefaas.
+var ErrNoCredentials = errors.New("volccreds: no function credentials available")
+
+// Provider implements credentials.Provider and credentials.Expirer of the
+// Volcengine Go SDK with the function credentials.
+type Provider struct {
+	// ctx is the invocation context, the latest credentials of the process are
+	// used if nil.
+	ctx context.Context
+
+	mu        sync.Mutex
+	retrieved vefaascontext.Credentials
+}
+
+var (
+	_ credentials.Provider = (*Provider)(nil)
+	_ credentials.Expirer  = (*Provider)(nil)
+)
+
+// NewProvider creates a Provider with the credentials of the invocation ctx.
+func NewProvider(ctx context.Context) *Provider {
+	return &Provider{ctx: ctx}
+}
+
+// NewProcessProvider creates a Provider with the most recent credentials seen by
+// the process, see vefaascontext.LatestCredentials. The provider expires once
+// the platform hands out new credentials, so that the SDK retrieves them again.
+func NewProcessProvider() *Provider {
+	return &Provider{}
+}
+
+// NewCredentials creates the SDK credentials of the invocation ctx.
+func NewCredentials(ctx context.Context) *credentials.Credentials {
+	return credentials.NewCredentials(NewProvider(ctx))
+}
+
+// NewProcessCredentials creates the SDK credentials following the most recent
+// credentials seen by the process.
+func NewProcessCredentials() *credentials.Credentials {
+	return credentials.NewCredentials(NewProcessProvider())
+}
+
+// Retrieve implements credentials.Provider.
+func (p *Provider) Retrieve() (credentials.Value, error) {
+	c := p.current()
+	if !c.IsValid() {
+		return credentials.Value{ProviderName: ProviderName}, ErrNoCredentials
+	}
+
+	p.mu.Lock()
+	p.retrieved = c
+	p.mu.Unlock()
+
+	return credentials.Value{
+		AccessKeyID:     c.AccessKeyId,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+		ProviderName:    ProviderName,
+	}, nil
+}
+
+// IsExpired implements credentials.Provider, it reports true if the retrieved
+// credentials have expired, or have been replaced by newer ones.
+func (p *Provider) IsExpired() bool {
+	c := p.current()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return !c.Equal(p.retrieved) || p.retrieved.Expired(time.Now())
+}
+
+// ExpiresAt implements credentials.Expirer, it returns the zero time if the
+// expiration is unknown.
+func (p *Provider) ExpiresAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.retrieved.Expiration
+}
+
+func (p *Provider) current() vefaascontext.Credentials {
+	if p.ctx != nil {
+		return vefaascontext.CredentialsFromContext(p.ctx)
+	}
+	return vefaascontext.LatestCredentials()
+}
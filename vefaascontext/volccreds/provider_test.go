@@ -0,0 +1,94 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package volccreds
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+)
+
+func invocationContext(ak, sk, token string, expiration time.Time) context.Context {
+	rq := httptest.NewRequest("POST", "/", nil)
+	rq.Header.Set("X-Faas-Access-Key-Id", ak)
+	rq.Header.Set("X-Faas-Secret-Access-Key", sk)
+	rq.Header.Set("X-Faas-Session-Token", token)
+	if !expiration.IsZero() {
+		rq.Header.Set("X-Faas-Credentials-Expiration", expiration.Format(time.RFC3339))
+	}
+	return vefaascontext.WithCredentialsContext(context.Background(), rq)
+}
+
+// platformCredentials records the credentials handed out by the platform.
+func platformCredentials(ak, sk, token string) {
+	vefaascontext.RecordLatestCredentials(vefaascontext.Credentials{AccessKeyId: ak, SecretAccessKey: sk, SessionToken: token})
+}
+
+func TestProvider(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+	creds := NewCredentials(invocationContext("ak1", "sk1", "token1", expiration))
+
+	v, err := creds.Get()
+	if err != nil {
+		t.Fatalf("get credentials: %v", err)
+	}
+	if v.AccessKeyID != "ak1" || v.SecretAccessKey != "sk1" || v.SessionToken != "token1" || v.ProviderName != ProviderName {
+		t.Errorf("unexpected credentials %+v", v)
+	}
+	if at, err := creds.ExpiresAt(); err != nil || !at.Equal(expiration) {
+		t.Errorf("expires at %v, %v, want %v", at, err, expiration)
+	}
+	if creds.IsExpired() {
+		t.Error("credentials expired unexpectedly")
+	}
+
+	if _, err := NewCredentials(context.Background()).Get(); err == nil {
+		t.Error("expected error without credentials")
+	}
+}
+
+func TestProcessProvider(t *testing.T) {
+	platformCredentials("ak1", "sk1", "token1")
+	creds := NewProcessCredentials()
+
+	v, err := creds.Get()
+	if err != nil || v.AccessKeyID != "ak1" {
+		t.Fatalf("get credentials: %+v, %v", v, err)
+	}
+	if creds.IsExpired() {
+		t.Error("credentials expired unexpectedly")
+	}
+
+	// Credentials rotated by the platform.
+	platformCredentials("ak2", "sk2", "token2")
+	if !creds.IsExpired() {
+		t.Error("credentials not expired after rotation")
+	}
+	if v, err = creds.Get(); err != nil || v.AccessKeyID != "ak2" {
+		t.Errorf("get rotated credentials: %+v, %v", v, err)
+	}
+
+	// Neither the invalid credentials nor the invocations reset the latest ones.
+	platformCredentials("", "", "")
+	invocationContext("ak3", "sk3", "token3", time.Time{})
+	if creds.IsExpired() {
+		t.Error("credentials expired by invocation")
+	}
+}
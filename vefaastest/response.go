@@ -0,0 +1,72 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaastest
+
+import (
+	"net/http"
+	"testing"
+)
+
+// Response is the response of an invocation.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// ErrorCode returns the X-Faas-Response-Error-Code header, which is empty if the
+// invocation succeeded.
+func (r *Response) ErrorCode() string {
+	return r.Header.Get("X-Faas-Response-Error-Code")
+}
+
+// ErrorMessage returns the X-Faas-Response-Error-Message header.
+func (r *Response) ErrorMessage() string {
+	return r.Header.Get("X-Faas-Response-Error-Message")
+}
+
+// AssertStatus fails t if the status code of r is not statusCode.
+func (r *Response) AssertStatus(t testing.TB, statusCode int) {
+	t.Helper()
+	if r.StatusCode != statusCode {
+		t.Errorf("vefaastest: status code = %d, want %d, body: %s", r.StatusCode, statusCode, r.Body)
+	}
+}
+
+// AssertErrorCode fails t if the X-Faas-Response-Error-Code header of r is not code.
+func (r *Response) AssertErrorCode(t testing.TB, code string) {
+	t.Helper()
+	if got := r.ErrorCode(); got != code {
+		t.Errorf("vefaastest: error code = %q, want %q, message: %s", got, code, r.ErrorMessage())
+	}
+}
+
+// AssertNoError fails t if the invocation reported an error.
+func (r *Response) AssertNoError(t testing.TB) {
+	t.Helper()
+	if code := r.ErrorCode(); code != "" {
+		t.Errorf("vefaastest: unexpected error %q: %s", code, r.ErrorMessage())
+	}
+}
+
+// AssertBody fails t if the body of r is not body.
+func (r *Response) AssertBody(t testing.TB, body string) {
+	t.Helper()
+	if string(r.Body) != body {
+		t.Errorf("vefaastest: body = %q, want %q", r.Body, body)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package vefaastest provides utilities for testing vefaas functions in process.
+//
+// Invocations go through the same code path as the runtime server started by
+// vefaas.Start, including header parsing, vefaascontext injection, CloudEvent
+// binding and error reporting, without binding any port.
+package vefaastest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/vefaas"
+)
+
+// Function is a vefaas function under test.
+type Function struct {
+	handler http.Handler
+	// internalToken authenticates the internal requests to the function.
+	internalToken string
+}
+
+// NewFunction creates a Function with provided handler and options, see
+// vefaas.Start for the supported handler signatures. The internal requests,
+// like Initialize, are authenticated with a token generated for the Function,
+// which takes precedence over vefaas.WithInternalToken.
+func NewFunction(handler interface{}, opts ...vefaas.Option) *Function {
+	token := strconv.FormatUint(rand.Uint64(), 16)
+	opts = append(opts[:len(opts):len(opts)], vefaas.WithInternalToken(token))
+
+	return &Function{handler: vefaas.NewHandler(handler, opts...), internalToken: token}
+}
+
+// Initialize calls the initializer of the function like the platform does before
+// any invocation. It returns an error if the initialization failed.
+func (f *Function) Initialize(ctx context.Context) error {
+	rq := httptest.NewRequest(http.MethodPost, "/v1/initialize", nil).WithContext(ctx)
+	rq.RemoteAddr = "127.0.0.1:0"
+	rq.Header.Set("X-Faas-Internal-Request", "true")
+	rq.Header.Set("X-Faas-Internal-Token", f.internalToken)
+
+	resp := f.Invoke(rq)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("initialize function failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// InvokeHTTP invokes the function with rq as an http trigger request, which is
+// usually created by httptest.NewRequest.
+func (f *Function) InvokeHTTP(rq *http.Request, opts ...InvokeOption) *Response {
+	rq.Header.Set("X-Faas-Event-Type", events.EventTypeHTTP)
+
+	return f.Invoke(rq, opts...)
+}
+
+// InvokeCloudEvent invokes the function with event encoded in binary mode, like
+// timer, tos, kafka triggers do. See the event builders in package events, like
+// events.NewTimerEvent, which approximate the events of the platform.
+func (f *Function) InvokeCloudEvent(event *events.CloudEvent, opts ...InvokeOption) *Response {
+	rq, err := events.NewBinaryRequest("http://localhost/", event)
+	if err != nil {
+		panic(fmt.Sprintf("vefaastest: failed to encode cloudevent, %v", err))
+	}
+
+	return f.Invoke(rq, opts...)
+}
+
+// InvokeCloudEventBatch invokes the function with batch encoded in the
+// application/cloudevents-batch+json format.
+func (f *Function) InvokeCloudEventBatch(batch []*events.CloudEvent, opts ...InvokeOption) *Response {
+	rq, err := events.NewBatchRequest("http://localhost/", batch...)
+	if err != nil {
+		panic(fmt.Sprintf("vefaastest: failed to encode cloudevent batch, %v", err))
+	}
+
+	return f.Invoke(rq, opts...)
+}
+
+// InvokeHTTPRequest invokes the function with r as an http trigger request, see
+// events.NewHTTPRequest.
+func (f *Function) InvokeHTTPRequest(r *events.HTTPRequest, opts ...InvokeOption) *Response {
+	rq, err := r.NewRequest("http://localhost/")
+	if err != nil {
+		panic(fmt.Sprintf("vefaastest: failed to encode http request, %v", err))
+	}
+
+	return f.Invoke(rq, opts...)
+}
+
+// Invoke sends rq to the function as is, after applying opts. A request id is
+// generated if rq carries none.
+func (f *Function) Invoke(rq *http.Request, opts ...InvokeOption) *Response {
+	for _, opt := range opts {
+		opt(rq)
+	}
+	if rq.Header.Get("X-Faas-Request-Id") == "" && rq.Header.Get("X-Faas-Internal-Request") != "true" {
+		rq.Header.Set("X-Faas-Request-Id", "vefaastest-"+strconv.FormatUint(rand.Uint64(), 16))
+	}
+
+	rec := httptest.NewRecorder()
+	f.handler.ServeHTTP(rec, rq)
+	result := rec.Result()
+
+	return &Response{
+		StatusCode: result.StatusCode,
+		Header:     result.Header,
+		Body:       rec.Body.Bytes(),
+	}
+}
+
+// InvokeOption customizes the invocation request.
+type InvokeOption func(rq *http.Request)
+
+// WithRequestId sets the request id of the invocation.
+func WithRequestId(id string) InvokeOption {
+	return func(rq *http.Request) {
+		rq.Header.Set("X-Faas-Request-Id", id)
+	}
+}
+
+// WithCredentials sets the temporary credentials of the invocation, which are
+// available through vefaascontext.
+func WithCredentials(accessKeyId, secretAccessKey, sessionToken string) InvokeOption {
+	return func(rq *http.Request) {
+		rq.Header.Set("X-Faas-Access-Key-Id", accessKeyId)
+		rq.Header.Set("X-Faas-Secret-Access-Key", secretAccessKey)
+		rq.Header.Set("X-Faas-Session-Token", sessionToken)
+	}
+}
+
+// WithHeader sets a header of the invocation request.
+func WithHeader(key, value string) InvokeOption {
+	return func(rq *http.Request) {
+		rq.Header.Set(key, value)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vefaastest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/volcengine/vefaas-golang-runtime/events"
+	"github.com/volcengine/vefaas-golang-runtime/vefaas"
+	"github.com/volcengine/vefaas-golang-runtime/vefaascontext"
+	"github.com/volcengine/vefaas-golang-runtime/vefaastest"
+)
+
+func TestFunction(t *testing.T) {
+	initialized := 0
+	initializer := func(ctx context.Context) error {
+		initialized++
+		return nil
+	}
+	handler := func(ctx context.Context, payload interface{}) (*events.EventResponse, error) {
+		switch p := payload.(type) {
+		case *events.HTTPRequest:
+			if p.QueryStringParameters["name"] == "" {
+				return nil, vefaas.BadRequest("name is required")
+			}
+			body := fmt.Sprintf("hello %s from %s with %s", p.QueryStringParameters["name"],
+				vefaascontext.RequestIdFromContext(ctx), vefaascontext.AccessKeyIdFromContext(ctx))
+			return &events.EventResponse{Body: []byte(body)}, nil
+		case *events.CloudEvent:
+			if p.Type() != events.FaasTimerEvent {
+				return nil, errors.New("unexpected event")
+			}
+			return &events.EventResponse{Body: []byte(p.ID())}, nil
+		}
+		return nil, nil
+	}
+	// The internal token configured for the platform does not break Initialize.
+	fn := vefaastest.NewFunction(handler, vefaas.WithInitializer(initializer), vefaas.WithInternalToken("t0ken"))
+
+	if err := fn.Initialize(context.Background()); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if err := fn.Initialize(context.Background()); err != nil || initialized != 1 {
+		t.Fatalf("initializer executed %d times, err: %v", initialized, err)
+	}
+
+	resp := fn.InvokeHTTP(httptest.NewRequest(http.MethodGet, "/?name=vefaas", nil),
+		vefaastest.WithRequestId("req-1"), vefaastest.WithCredentials("ak", "sk", "token"))
+	resp.AssertNoError(t)
+	resp.AssertStatus(t, http.StatusOK)
+	resp.AssertBody(t, "hello vefaas from req-1 with ak")
+
+	resp = fn.InvokeHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+	resp.AssertStatus(t, http.StatusBadRequest)
+	resp.AssertErrorCode(t, "bad_request")
+
+	event := events.NewTimerEvent("every-minute", "")
+	event.SetID("event-1")
+	resp = fn.InvokeCloudEvent(event)
+	resp.AssertNoError(t)
+	resp.AssertBody(t, "event-1")
+
+	resp = fn.InvokeCloudEvent(events.NewKafkaEvent("orders", []byte("order-1")))
+	resp.AssertStatus(t, http.StatusInternalServerError)
+	resp.AssertErrorCode(t, "function_execution_error")
+}
+
+func TestInitializeFailure(t *testing.T) {
+	initializer := func(ctx context.Context) error {
+		return errors.New("database unreachable")
+	}
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		return &events.EventResponse{}, nil
+	}
+	fn := vefaastest.NewFunction(handler, vefaas.WithInitializer(initializer))
+
+	if err := fn.Initialize(context.Background()); err == nil {
+		t.Fatal("expected initialize error")
+	}
+}
+
+func ExampleFunction_InvokeHTTP() {
+	handler := func(ctx context.Context, r *events.HTTPRequest) (*events.EventResponse, error) {
+		return &events.EventResponse{Body: []byte("Hello veFaaS!")}, nil
+	}
+
+	fn := vefaastest.NewFunction(handler)
+	resp := fn.InvokeHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+	fmt.Println(resp.StatusCode, string(resp.Body))
+	// Output: 200 Hello veFaaS!
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Dial opens a WebSocket connection to rawURL, a ws:// url, with the extra
+// headers of the opening handshake. The response of the handshake is returned
+// along with the connection, or an error if the server refuses to upgrade.
+//
+// It's meant for tests and plain upstream connections, wss:// is not supported.
+func Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, *http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch u.Scheme {
+	case "ws":
+	case "http":
+		u.Scheme = "ws"
+	default:
+		return nil, nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	var nonce [16]byte
+	_, _ = rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	rq := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+	for k, v := range header {
+		rq.Header[k] = v
+	}
+	rq.Header.Set("Upgrade", "websocket")
+	rq.Header.Set("Connection", "Upgrade")
+	rq.Header.Set("Sec-Websocket-Key", key)
+	rq.Header.Set("Sec-Websocket-Version", "13")
+	if err := rq.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, rq)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!headerContainsToken(resp.Header, "Upgrade", "websocket") ||
+		resp.Header.Get("Sec-Websocket-Accept") != acceptKey(key) {
+		_ = conn.Close()
+		return nil, resp, fmt.Errorf("websocket: bad handshake, status %s", resp.Status)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	c := newConn(conn, br, false)
+	c.Subprotocol = strings.TrimSpace(resp.Header.Get("Sec-Websocket-Protocol"))
+
+	return c, resp, nil
+}
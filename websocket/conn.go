@@ -0,0 +1,499 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package websocket implements the WebSocket protocol of RFC 6455 for functions,
+// with the server side upgrade of http requests and a client for tests and
+// upstream connections. Compression extensions are not supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the type of a data message.
+type MessageType int
+
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// Close codes, see https://www.rfc-editor.org/rfc/rfc6455#section-7.4.1.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+	CloseTryAgainLater           = 1013
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	finBit  = 0x80
+	rsvBits = 0x70
+	maskBit = 0x80
+
+	maxControlPayload = 125
+
+	// DefaultReadLimit is the default max size of a message read.
+	DefaultReadLimit = 32 << 20
+
+	closeTimeout = time.Second
+)
+
+// ErrClosed is returned when using a closed connection.
+var ErrClosed = errors.New("websocket: use of closed connection")
+
+// CloseError is returned by ReadMessage when the peer closes the connection.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("websocket: close %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// IsCloseError reports whether err is a *CloseError with one of codes.
+func IsCloseError(err error, codes ...int) bool {
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	for _, code := range codes {
+		if ce.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a WebSocket connection. ReadMessage should be called from a single
+// goroutine, while the write methods and Close are safe for concurrent use.
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	server bool
+
+	readLimit   int64
+	pongHandler func(data []byte)
+
+	// reading is held by the reader of the connection, either ReadMessage, or
+	// Close waiting for the close message of the peer.
+	reading chan struct{}
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closeSent bool
+	closed    chan struct{}
+
+	// Subprotocol is the negotiated subprotocol, if any.
+	Subprotocol string
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, server bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	return &Conn{
+		conn:      conn,
+		br:        br,
+		server:    server,
+		readLimit: DefaultReadLimit,
+		reading:   make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+	}
+}
+
+// SetReadLimit sets the max size of a message read, a larger message closes the
+// connection with CloseMessageTooBig.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetPongHandler sets the handler of the pong messages received in
+// ReadMessage. Pings are answered automatically.
+func (c *Conn) SetPongHandler(h func(data []byte)) {
+	c.pongHandler = h
+}
+
+// SetReadDeadline sets the deadline of the underlying connection for reads.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline of the underlying connection for writes.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Done is closed once the connection is closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.closed
+}
+
+// ReadMessage reads the next data message. Control messages are handled in
+// place, a close from the peer is answered and returned as a *CloseError.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	select {
+	case c.reading <- struct{}{}:
+		defer func() { <-c.reading }()
+	case <-c.closed:
+		return 0, nil, ErrClosed
+	}
+
+	var (
+		messageType MessageType
+		message     []byte
+		started     bool
+	)
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, c.readError(err)
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil && err != ErrClosed {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			if c.pongHandler != nil {
+				c.pongHandler(payload)
+			}
+			continue
+		case opClose:
+			ce := parseClosePayload(payload)
+			if ce == nil {
+				return 0, nil, c.fail(CloseProtocolError, "invalid close frame")
+			}
+			replyCode := ce.Code
+			if replyCode == CloseNoStatusReceived {
+				replyCode = CloseNormalClosure
+			}
+			// No-op if the close message is sent already, to which this replies.
+			_ = c.sendClose(replyCode, "")
+			_ = c.closeConn()
+			return 0, nil, ce
+		case opText, opBinary:
+			if started {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			started, messageType = true, MessageType(opcode)
+		case opContinuation:
+			if !started {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+
+		if int64(len(message)+len(payload)) > c.readLimit {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		message = append(message, payload...)
+		if !fin {
+			continue
+		}
+		if messageType == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid utf-8")
+		}
+		if message == nil {
+			message = []byte{}
+		}
+		return messageType, message, nil
+	}
+}
+
+// WriteMessage writes data as a single message of messageType.
+func (c *Conn) WriteMessage(messageType MessageType, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return c.writeFrame(byte(messageType), data)
+}
+
+// Ping sends a ping with data, which must not exceed 125 bytes.
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control payload too large")
+	}
+	return c.writeFrame(opPing, data)
+}
+
+// Close sends a close message with code and text, waits for the close message
+// of the peer up to a second, then closes the underlying connection. The text
+// is cut to fit in a control frame. It's a no-op on a closing connection.
+func (c *Conn) Close(code int, text string) error {
+	err := c.sendClose(code, text)
+	if err == ErrClosed {
+		return nil
+	}
+	if err == nil {
+		c.awaitPeerClose()
+	}
+	if cerr := c.closeConn(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// sendClose sends the close message, it returns ErrClosed if sent already.
+func (c *Conn) sendClose(code int, text string) error {
+	if n := maxControlPayload - 2; len(text) > n {
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+		text = text[:n]
+	}
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, text...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	c.closeSent = true
+	_ = c.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+	return c.writeFrameLocked(opClose, payload)
+}
+
+// awaitPeerClose waits for the close message of the peer until closeTimeout,
+// which is read by ReadMessage if in progress, otherwise read here.
+func (c *Conn) awaitPeerClose() {
+	deadline := time.Now().Add(closeTimeout)
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-c.closed:
+	case <-timer.C:
+	case c.reading <- struct{}{}:
+		defer func() { <-c.reading }()
+		_ = c.conn.SetReadDeadline(deadline)
+		for {
+			_, opcode, _, err := c.readFrame()
+			if err != nil || opcode == opClose {
+				return
+			}
+		}
+	}
+}
+
+// closeConn closes the underlying connection.
+func (c *Conn) closeConn() error {
+	var err error
+	c.closeOnce.Do(func() {
+		err = c.conn.Close()
+		close(c.closed)
+	})
+	return err
+}
+
+// fail closes the connection for a protocol violation detected while reading,
+// without waiting for the peer.
+func (c *Conn) fail(code int, text string) error {
+	_ = c.sendClose(code, text)
+	_ = c.closeConn()
+	return &CloseError{Code: code, Text: text}
+}
+
+// readError maps the errors of the underlying connection.
+func (c *Conn) readError(err error) error {
+	select {
+	case <-c.closed:
+		return ErrClosed
+	default:
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		_ = c.closeConn()
+		return &CloseError{Code: CloseAbnormalClosure, Text: "unexpected EOF"}
+	}
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		return c.fail(ce.Code, ce.Text)
+	}
+	return err
+}
+
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&finBit != 0
+	opcode = head[0] & 0x0f
+	masked := head[1]&maskBit != 0
+
+	if head[0]&rsvBits != 0 {
+		err = &CloseError{Code: CloseProtocolError, Text: "reserved bits set"}
+		return
+	}
+	if masked != c.server {
+		err = &CloseError{Code: CloseProtocolError, Text: "invalid frame masking"}
+		return
+	}
+
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var b [2]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err = io.ReadFull(c.br, b[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(b[:])
+	}
+	if opcode >= opClose && (length > maxControlPayload || !fin) {
+		err = &CloseError{Code: CloseProtocolError, Text: "invalid control frame"}
+		return
+	}
+	if length > uint64(c.readLimit) {
+		err = &CloseError{Code: CloseMessageTooBig, Text: "message too big"}
+		return
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, key[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(key, payload)
+	}
+
+	return
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+// writeFrameLocked writes a frame with writeMu held.
+func (c *Conn) writeFrameLocked(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, finBit|opcode)
+	var maskFlag byte
+	if !c.server {
+		maskFlag = maskBit
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskFlag|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskFlag|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, maskFlag|127)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(n))
+		frame = append(frame, b[:]...)
+	}
+
+	if c.server {
+		frame = append(frame, payload...)
+	} else {
+		// Clients must mask the frames with a random key.
+		var key [4]byte
+		_, _ = rand.Read(key[:])
+		frame = append(frame, key[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(key, frame[start:])
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
+
+// parseClosePayload parses the payload of a close frame, it returns nil if the
+// payload is malformed.
+func parseClosePayload(payload []byte) *CloseError {
+	switch {
+	case len(payload) == 0:
+		return &CloseError{Code: CloseNoStatusReceived}
+	case len(payload) == 1:
+		return nil
+	}
+	code := int(binary.BigEndian.Uint16(payload))
+	text := payload[2:]
+	if !validCloseCode(code) || !utf8.Valid(text) {
+		return nil
+	}
+	return &CloseError{Code: code, Text: string(text)}
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code >= 1000 && code <= 1014:
+		return code != 1004 && code != CloseNoStatusReceived && code != CloseAbnormalClosure
+	}
+	return false
+}
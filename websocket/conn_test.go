@@ -0,0 +1,186 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func newEchoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("X-Faas-Request-Id", "req-1")
+		c, err := Upgrade(rw, r, "chat")
+		if err != nil {
+			return
+		}
+		for {
+			messageType, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(data) == "bye" {
+				_ = c.Close(4000, "bye")
+				return
+			}
+			if err := c.WriteMessage(messageType, data); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestEcho(t *testing.T) {
+	ts := newEchoServer(t)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	header := http.Header{"Sec-Websocket-Protocol": {"v2, chat"}}
+	c, resp, err := Dial(ctx, ts.URL, header)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	if c.Subprotocol != "chat" || resp.Header.Get("X-Faas-Request-Id") != "req-1" {
+		t.Errorf("subprotocol = %q, request id = %q", c.Subprotocol, resp.Header.Get("X-Faas-Request-Id"))
+	}
+
+	messages := []struct {
+		messageType MessageType
+		data        []byte
+	}{
+		{TextMessage, []byte("hello")},
+		{BinaryMessage, []byte{0, 1, 2}},
+		{TextMessage, []byte{}},
+		{BinaryMessage, bytes.Repeat([]byte("x"), 70000)},
+	}
+	for _, m := range messages {
+		if err := c.WriteMessage(m.messageType, m.data); err != nil {
+			t.Fatalf("WriteMessage() error = %v", err)
+		}
+		messageType, data, err := c.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage() error = %v", err)
+		}
+		if messageType != m.messageType || !bytes.Equal(data, m.data) {
+			t.Errorf("echo = %d %d bytes, want %d %d bytes", messageType, len(data), m.messageType, len(m.data))
+		}
+	}
+
+	pong := make(chan string, 1)
+	c.SetPongHandler(func(data []byte) { pong <- string(data) })
+	if err := c.Ping([]byte("p")); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	_ = c.WriteMessage(TextMessage, []byte("after ping"))
+	if _, data, err := c.ReadMessage(); err != nil || string(data) != "after ping" {
+		t.Fatalf("ReadMessage() = %q, %v", data, err)
+	}
+	select {
+	case p := <-pong:
+		if p != "p" {
+			t.Errorf("pong = %q, want %q", p, "p")
+		}
+	default:
+		t.Error("no pong received")
+	}
+
+	_ = c.WriteMessage(TextMessage, []byte("bye"))
+	_, _, err = c.ReadMessage()
+	if !IsCloseError(err, 4000) || !strings.Contains(err.Error(), "bye") {
+		t.Errorf("ReadMessage() error = %v, want close 4000", err)
+	}
+	if err := c.WriteMessage(TextMessage, []byte("closed")); err != ErrClosed {
+		t.Errorf("WriteMessage() after close error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestUnmaskedClientFrame(t *testing.T) {
+	ts := newEchoServer(t)
+	defer ts.Close()
+
+	c, _, err := Dial(context.Background(), ts.URL, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	// Pretend to be a server, which doesn't mask frames.
+	c.server = true
+	_ = c.WriteMessage(TextMessage, []byte("unmasked"))
+	c.server = false
+
+	_, _, err = c.ReadMessage()
+	if !IsCloseError(err, CloseProtocolError) {
+		t.Errorf("ReadMessage() error = %v, want close %d", err, CloseProtocolError)
+	}
+}
+
+func TestUpgradeRequired(t *testing.T) {
+	ts := newEchoServer(t)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired || resp.Header.Get("Sec-Websocket-Version") != "13" {
+		t.Errorf("status = %d, version = %q", resp.StatusCode, resp.Header.Get("Sec-Websocket-Version"))
+	}
+}
+
+func TestCloseHandshake(t *testing.T) {
+	a, b := net.Pipe()
+	server, client := newConn(a, nil, true), newConn(b, nil, false)
+	defer client.closeConn()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Close(CloseGoingAway, strings.Repeat("é", maxControlPayload))
+	}()
+
+	_, opcode, payload, err := client.readFrame()
+	if err != nil || opcode != opClose {
+		t.Fatalf("readFrame() = %d, %v, want close", opcode, err)
+	}
+	if len(payload) > maxControlPayload || !utf8.Valid(payload[2:]) {
+		t.Errorf("close reason %q is not cut at a rune boundary", payload[2:])
+	}
+
+	// The connection is kept open for the close message of the peer.
+	select {
+	case err := <-done:
+		t.Fatalf("Close() = %v before the peer replied", err)
+	case <-time.After(closeTimeout / 10):
+	}
+	if err := client.writeFrame(opClose, payload[:2]); err != nil {
+		t.Fatalf("reply close error = %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	case <-time.After(closeTimeout / 2):
+		t.Error("Close() does not return once the peer replied")
+	}
+}
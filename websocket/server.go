@@ -0,0 +1,161 @@
+/*
+ * Copyright 2022 Volcengine
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// acceptGUID is the magic GUID of the Sec-WebSocket-Accept computation.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// HandshakeError describes a failed opening handshake.
+type HandshakeError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Message
+}
+
+// IsUpgradeRequest reports whether r asks for a WebSocket upgrade.
+func IsUpgradeRequest(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// CheckUpgrade validates the opening handshake of r, it returns a
+// *HandshakeError if r is not a valid WebSocket upgrade request.
+func CheckUpgrade(r *http.Request) error {
+	switch {
+	case !IsUpgradeRequest(r):
+		return &HandshakeError{StatusCode: http.StatusUpgradeRequired, Message: "not a websocket upgrade request"}
+	case r.Method != http.MethodGet:
+		return &HandshakeError{StatusCode: http.StatusMethodNotAllowed, Message: "upgrade request method is not GET"}
+	case r.ProtoMajor != 1:
+		return &HandshakeError{StatusCode: http.StatusHTTPVersionNotSupported, Message: "upgrade requires HTTP/1.1"}
+	case r.Header.Get("Sec-Websocket-Version") != "13":
+		return &HandshakeError{StatusCode: http.StatusUpgradeRequired, Message: "unsupported websocket version"}
+	}
+	key, err := base64.StdEncoding.DecodeString(r.Header.Get("Sec-Websocket-Key"))
+	if err != nil || len(key) != 16 {
+		return &HandshakeError{StatusCode: http.StatusBadRequest, Message: "invalid Sec-WebSocket-Key"}
+	}
+
+	return nil
+}
+
+// Upgrade upgrades the http request to a WebSocket connection. The headers
+// already set on rw are sent along with the handshake response. If the
+// handshake fails, an error response is written and a *HandshakeError is
+// returned.
+//
+// The subprotocol is selected in the order requested by the client, the first
+// one found in subprotocols, if any.
+func Upgrade(rw http.ResponseWriter, r *http.Request, subprotocols ...string) (*Conn, error) {
+	if err := CheckUpgrade(r); err != nil {
+		he := err.(*HandshakeError)
+		if he.StatusCode == http.StatusUpgradeRequired {
+			rw.Header().Set("Sec-Websocket-Version", "13")
+		}
+		http.Error(rw, he.Message, he.StatusCode)
+		return nil, err
+	}
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, errors.New("websocket: response writer does not implement http.Hijacker")
+	}
+
+	subprotocol := selectSubprotocol(r, subprotocols)
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-Websocket-Key")) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	for k, vs := range rw.Header() {
+		if k == "Content-Type" || k == "Content-Length" {
+			continue
+		}
+		for _, v := range vs {
+			b.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	b.WriteString("\r\n")
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	var br *bufio.Reader
+	if brw != nil {
+		br = brw.Reader
+	}
+	c := newConn(conn, br, true)
+	c.Subprotocol = subprotocol
+
+	return c, nil
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func selectSubprotocol(r *http.Request, supported []string) string {
+	for _, requested := range headerTokens(r.Header, "Sec-Websocket-Protocol") {
+		for _, s := range supported {
+			if requested == s {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}